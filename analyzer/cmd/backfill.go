@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"bazel-metrics/analyzer/pkg/history"
+	"bazel-metrics/analyzer/pkg/metrics"
+	"bazel-metrics/analyzer/pkg/scanner"
+)
+
+// backfill computes metrics for past commits of a git repository, reading
+// each commit's tree from git objects rather than checking it out
+func backfill(args []string) {
+	var (
+		repoPath    string
+		ref         string
+		since       string
+		until       string
+		interval    string
+		outputDir   string
+		historyPath string
+		prettyPrint bool
+		configPath  string
+		workers     int
+		cachePath   string
+		noCache     bool
+		excludeGen  bool
+	)
+
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	flags.StringVar(&repoPath, "repo", ".", "Path to the git repository to analyze")
+	flags.StringVar(&ref, "ref", "HEAD", "Branch or commit whose history is sampled")
+	flags.StringVar(&since, "since", "", "First date to sample, YYYY-MM-DD or RFC 3339 (default: a year before --until)")
+	flags.StringVar(&until, "until", "", "Last date to sample, YYYY-MM-DD or RFC 3339 (default: now)")
+	flags.StringVar(&interval, "interval", "1w", "Time between samples, e.g. 1d, 1w or 12h")
+	flags.StringVar(&outputDir, "output-dir", "", "Directory to write one full report per sampled commit to")
+	flags.StringVar(&historyPath, "history", "", "Combined history file for trend charts (default: history.json unless --output-dir is set)")
+	flags.BoolVar(&prettyPrint, "pretty", true, "Pretty print JSON output")
+	flags.StringVar(&configPath, "config", "", "Path to analyzer config JSON (custom rule/macro mappings)")
+	flags.IntVar(&workers, "workers", 0, "Number of concurrent scan workers (default: number of CPUs)")
+	flags.StringVar(&cachePath, "cache", "", "Scan cache file (default: under the user cache directory)")
+	flags.BoolVar(&noCache, "no-cache", false, "Disable the scan cache")
+	flags.BoolVar(&excludeGen, "exclude-generated", false, "Leave packages with only generated source files out of the package totals and percentages")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	absRepoPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving path: %v\n", err)
+		os.Exit(1)
+	}
+
+	end := time.Now().UTC()
+	if until != "" {
+		if end, err = history.ParseDate(until); err != nil {
+			fmt.Fprintf(os.Stderr, "--until: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	start := end.AddDate(-1, 0, 0)
+	if since != "" {
+		if start, err = history.ParseDate(since); err != nil {
+			fmt.Fprintf(os.Stderr, "--since: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	step, err := history.ParseInterval(interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--interval: %v\n", err)
+		os.Exit(1)
+	}
+	if outputDir == "" && historyPath == "" {
+		historyPath = "history.json"
+	}
+
+	scanOpts := []scanner.Option{scanner.WithWorkers(workers)}
+	if configPath != "" {
+		cfg, err := scanner.LoadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+			os.Exit(1)
+		}
+		scanOpts = append(scanOpts, scanner.WithConfig(cfg))
+	}
+	// Consecutive commits share most directories, and git blob ids make
+	// their cache fingerprints comparable across commits
+	if !noCache {
+		if cachePath == "" {
+			cachePath, err = scanner.DefaultCachePath(absRepoPath, "git")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: scan cache disabled: %v\n", err)
+		} else {
+			scanOpts = append(scanOpts, scanner.WithCache(cachePath, false))
+		}
+	}
+
+	commits, err := history.Sample(ctx, absRepoPath, ref, start, end, step)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing commits: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Backfilling %s: %d commits of %s from %s to %s, every %s\n",
+		absRepoPath, len(commits), ref, start.Format(time.DateOnly), end.Format(time.DateOnly), interval)
+	if outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", outputDir, err)
+			os.Exit(1)
+		}
+	}
+
+	hist := &metrics.History{
+		RepoPath: absRepoPath,
+		Ref:      ref,
+		Interval: interval,
+		Points:   make([]*metrics.HistoryPoint, 0, len(commits)),
+	}
+	for i, commit := range commits {
+		s := scanner.NewScanner(absRepoPath, append(scanOpts, scanner.WithGitTree(commit.Hash))...)
+		scanResult, err := s.ScanContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintf(os.Stderr, "Interrupted: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", commit.Hash[:12], err)
+			continue
+		}
+		report := metrics.NewCalculator(scanResult, metrics.WithExcludeGenerated(excludeGen)).Calculate()
+		report.Timestamp = commit.Time.Format(time.RFC3339)
+		report.Commit = commit.Hash
+
+		fmt.Printf("[%d/%d] %s %s: %d packages, %d BUILD files\n",
+			i+1, len(commits), commit.Time.Format(time.DateOnly), commit.Hash[:12],
+			countPackages(scanResult), scanResult.TotalBUILDs)
+
+		if outputDir != "" {
+			name := fmt.Sprintf("%s-%s.json", commit.Time.Format("2006-01-02T150405Z"), commit.Hash[:12])
+			if err := writeJSON(filepath.Join(outputDir, name), report, prettyPrint); err != nil {
+				fmt.Fprintf(os.Stderr, "Write error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		hist.Points = append(hist.Points, report.HistoryPoint())
+	}
+
+	if historyPath != "" {
+		fmt.Printf("\nWriting history to %s...\n", historyPath)
+		if err := writeJSON(historyPath, hist, prettyPrint); err != nil {
+			fmt.Fprintf(os.Stderr, "Write error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println("Done!")
+}
+
+// countPackages totals the packages of every language
+func countPackages(result *scanner.ScanResult) int {
+	n := 0
+	for _, lr := range result.Languages {
+		n += len(lr.Packages)
+	}
+	return n
+}
+
+// writeJSON writes v to path as JSON
+func writeJSON(path string, v any, pretty bool) error {
+	var (
+		data []byte
+		err  error
+	)
+	if pretty {
+		data, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 
 	"bazel-metrics/analyzer/pkg/benchmark"
 	"bazel-metrics/analyzer/pkg/metrics"
@@ -13,21 +17,48 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		backfill(os.Args[2:])
+		return
+	}
+
 	var (
 		repoPath      string
 		outputPath    string
 		runBenchmarks bool
 		maxBenchmarks int
 		prettyPrint   bool
+		configPath    string
+		workers       int
+		cachePath     string
+		noCache       bool
+		fullRescan    bool
+		goBackend     string
+		gitIndex      bool
+		gitTree       string
+		excludeGen    bool
 	)
 
-	flag.StringVar(&repoPath, "repo", ".", "Path to the repository to analyze")
+	flag.StringVar(&repoPath, "repo", ".", "Path to the repository to analyze, or a .tar.gz, .tgz, .tar.bz2, .tar or .zip archive of it")
 	flag.StringVar(&outputPath, "output", "metrics.json", "Output file path for metrics JSON")
 	flag.BoolVar(&runBenchmarks, "benchmark", false, "Run speed benchmarks (go test vs bazel test)")
 	flag.IntVar(&maxBenchmarks, "max-benchmarks", 5, "Maximum number of packages to benchmark")
 	flag.BoolVar(&prettyPrint, "pretty", true, "Pretty print JSON output")
+	flag.StringVar(&configPath, "config", "", "Path to analyzer config JSON (custom rule/macro mappings)")
+	flag.IntVar(&workers, "workers", 0, "Number of concurrent scan workers (default: number of CPUs)")
+	flag.StringVar(&cachePath, "cache", "", "Scan cache file (default: under the user cache directory)")
+	flag.BoolVar(&noCache, "no-cache", false, "Disable the scan cache")
+	flag.BoolVar(&fullRescan, "full-rescan", false, "Ignore cached scan results and rescan every directory")
+	flag.StringVar(&goBackend, "go-backend", "walk", "How to discover Go packages: walk (file suffixes) or golist (go list -json, falls back to walk)")
+	flag.BoolVar(&gitIndex, "git-index", false, "Scan only the files staged in the git index, ignoring untracked files")
+	flag.StringVar(&gitTree, "git-tree", "", "Scan the files of a git commit, branch or tag instead of the working tree")
+	flag.BoolVar(&excludeGen, "exclude-generated", false, "Leave packages with only generated source files out of the package totals and percentages")
 	flag.Parse()
 
+	// Stop scanning cleanly on Ctrl-C or when the job is terminated
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Resolve absolute path
 	absRepoPath, err := filepath.Abs(repoPath)
 	if err != nil {
@@ -36,73 +67,236 @@ func main() {
 	}
 
 	// Verify path exists
-	if _, err := os.Stat(absRepoPath); os.IsNotExist(err) {
+	info, err := os.Stat(absRepoPath)
+	if os.IsNotExist(err) {
 		fmt.Fprintf(os.Stderr, "Repository path does not exist: %s\n", absRepoPath)
 		os.Exit(1)
 	}
 
+	// A tarball or zip archive is scanned in memory instead of a checkout
+	isArchive := err == nil && !info.IsDir()
+	if isArchive {
+		switch {
+		case !scanner.IsArchive(absRepoPath):
+			fmt.Fprintf(os.Stderr, "Repository path is neither a directory nor a .tar.gz, .tgz, .tar.bz2, .tar or .zip archive: %s\n", absRepoPath)
+			os.Exit(1)
+		case gitIndex || gitTree != "":
+			fmt.Fprintf(os.Stderr, "--git-index and --git-tree need a git repository, not an archive\n")
+			os.Exit(1)
+		case runBenchmarks:
+			fmt.Fprintf(os.Stderr, "--benchmark needs a checkout to run tests in, not an archive\n")
+			os.Exit(1)
+		}
+	}
+
+	switch scanner.GoBackend(goBackend) {
+	case scanner.GoBackendWalk, scanner.GoBackendGoList:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown --go-backend %q (want walk or golist)\n", goBackend)
+		os.Exit(1)
+	}
+
+	scanOpts := []scanner.Option{
+		scanner.WithWorkers(workers),
+		scanner.WithGoBackend(scanner.GoBackend(goBackend)),
+	}
+	switch {
+	case isArchive:
+		fsys, err := scanner.OpenArchive(absRepoPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading archive: %v\n", err)
+			os.Exit(1)
+		}
+		scanOpts = append(scanOpts, scanner.WithFS(fsys))
+	case gitTree != "":
+		scanOpts = append(scanOpts, scanner.WithGitTree(gitTree))
+	case gitIndex:
+		scanOpts = append(scanOpts, scanner.WithGitIndex())
+	}
+	if configPath != "" {
+		cfg, err := scanner.LoadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+			os.Exit(1)
+		}
+		scanOpts = append(scanOpts, scanner.WithConfig(cfg))
+	}
+
+	if !noCache {
+		if cachePath == "" {
+			mode := ""
+			if gitIndex || gitTree != "" {
+				mode = "git"
+			}
+			cachePath, err = scanner.DefaultCachePath(absRepoPath, mode)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: scan cache disabled: %v\n", err)
+		} else {
+			scanOpts = append(scanOpts, scanner.WithCache(cachePath, fullRescan))
+		}
+	}
+
 	fmt.Printf("Analyzing repository: %s\n", absRepoPath)
 
 	// Scan repository
 	fmt.Println("Scanning for packages and BUILD files...")
-	s := scanner.NewScanner(absRepoPath)
-	scanResult, err := s.Scan()
+	s := scanner.NewScanner(absRepoPath, scanOpts...)
+	scanResult, err := s.ScanContext(ctx)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Scan error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Found: %d Go packages, %d Python packages, %d Rust packages, %d BUILD files\n",
-		len(scanResult.GoPackages),
-		len(scanResult.PythonPackages),
-		len(scanResult.RustPackages),
-		scanResult.TotalBUILDs)
+	// Languages without packages are left out to keep the line short
+	found := make([]string, 0, len(scanResult.Languages))
+	for _, lr := range scanResult.Languages {
+		if len(lr.Packages) > 0 {
+			found = append(found, fmt.Sprintf("%d %s packages", len(lr.Packages), lr.Name))
+		}
+	}
+	if len(found) == 0 {
+		found = append(found, "0 packages")
+	}
+	fmt.Printf("Found: %s, %d BUILD files\n", strings.Join(found, ", "), scanResult.TotalBUILDs)
+	if scanResult.GoBackend != scanner.GoBackend(goBackend) {
+		fmt.Printf("Go packages discovered with the %s backend (fallback)\n", scanResult.GoBackend)
+	}
+	if scanResult.Cache != nil {
+		fmt.Printf("Cache: %d directories reused, %d rescanned\n", scanResult.Cache.Hits, scanResult.Cache.Misses)
+	}
+	if len(scanResult.ExcludedPackages) > 0 {
+		fmt.Printf("Excluded: %d packages by ignore files, gazelle directives and toolchain rules\n", len(scanResult.ExcludedPackages))
+		byDirective := 0
+		for _, pkg := range scanResult.ExcludedPackages {
+			if pkg.Reason == scanner.ExcludedByGazelle || pkg.Reason == scanner.ExcludedByGazelleIgnore {
+				byDirective++
+			}
+		}
+		if byDirective > 0 {
+			fmt.Printf("  %d of them by gazelle directives (# gazelle:exclude, # gazelle:ignore)\n", byDirective)
+		}
+	}
 
 	// Calculate metrics
 	fmt.Println("Calculating metrics...")
-	calc := metrics.NewCalculator(scanResult)
+	calc := metrics.NewCalculator(scanResult, metrics.WithExcludeGenerated(excludeGen))
 	report := calc.Calculate()
 
 	// Print summary for each language
 	fmt.Println("\n=== Summary ===")
 
-	if goSum, ok := report.LanguageSummaries["go"]; ok {
-		fmt.Println("\n--- Go ---")
-		fmt.Printf("Packages:        %d\n", goSum.TotalPackages)
+	for _, lr := range scanResult.Languages {
+		sum, ok := report.LanguageSummaries[string(lr.Language)]
+		if !ok {
+			continue
+		}
+		fmt.Printf("\n--- %s ---\n", lr.Name)
+		fmt.Printf("Packages:        %d\n", sum.TotalPackages)
 		fmt.Printf("Bazelization:    %.1f%% (%d/%d packages have BUILD files)\n",
-			goSum.BazelizationPct, goSum.PackagesWithBuild, goSum.TotalPackages)
+			sum.BazelizationPct, sum.PackagesWithBuild, sum.TotalPackages)
+		if sum.PackagesOwnedByAncestor > 0 {
+			fmt.Printf("Owned:           %.1f%% (%d more packages are built by an ancestor BUILD file, %d unowned)\n",
+				sum.OwnedPct, sum.PackagesOwnedByAncestor, sum.UnownedPackages)
+		}
 		fmt.Printf("Test Coverage:   %.1f%% (%d/%d packages have tests)\n",
-			goSum.TestCoveragePct, goSum.PackagesWithTests, goSum.TotalPackages)
-		fmt.Printf("Bazelized Tests: %.1f%% (packages with tests that have go_test targets)\n",
-			goSum.BazelizedTestsPct)
-		fmt.Printf("Source Files:    %d\n", goSum.TotalSourceFiles)
-		fmt.Printf("Test Files:      %d\n", goSum.TotalTestFiles)
-		fmt.Printf("Test Targets:    %d\n", goSum.TotalTestTargets)
+			sum.TestCoveragePct, sum.PackagesWithTests, sum.TotalPackages)
+		if len(lr.TestRuleKinds) > 0 {
+			fmt.Printf("Bazelized Tests: %.1f%% (packages with tests that have %s targets)\n",
+				sum.BazelizedTestsPct, strings.Join(lr.TestRuleKinds, "/"))
+		}
+		if sum.TotalTestFiles > 0 {
+			fmt.Printf("Bazelized Files: %.1f%% (%d/%d test files are in a test target's srcs)\n",
+				sum.BazelizedTestFilesPct, sum.TotalTestFiles-sum.UncoveredTestFiles, sum.TotalTestFiles)
+		}
+		fmt.Printf("Source Files:    %d\n", sum.TotalSourceFiles)
+		if sum.TotalGeneratedFiles > 0 {
+			fmt.Printf("Generated Files: %d (%d packages have only generated files)\n",
+				sum.TotalGeneratedFiles, sum.GeneratedOnlyPackages)
+		}
+		fmt.Printf("Test Files:      %d\n", sum.TotalTestFiles)
+		fmt.Printf("Test Targets:    %d\n", sum.TotalTestTargets)
+		if sum.UncoveredSourceFiles > 0 {
+			fmt.Printf("Unlisted Files:  %d source files are in no target's srcs\n", sum.UncoveredSourceFiles)
+		}
 	}
 
-	if pySum, ok := report.LanguageSummaries["python"]; ok {
-		fmt.Println("\n--- Python ---")
-		fmt.Printf("Packages:        %d\n", pySum.TotalPackages)
-		fmt.Printf("Bazelization:    %.1f%% (%d/%d packages have BUILD files)\n",
-			pySum.BazelizationPct, pySum.PackagesWithBuild, pySum.TotalPackages)
-		fmt.Printf("Test Coverage:   %.1f%% (%d/%d packages have tests)\n",
-			pySum.TestCoveragePct, pySum.PackagesWithTests, pySum.TotalPackages)
-		fmt.Printf("Bazelized Tests: %.1f%% (packages with tests that have py_test targets)\n",
-			pySum.BazelizedTestsPct)
-		fmt.Printf("Source Files:    %d\n", pySum.TotalSourceFiles)
-		fmt.Printf("Test Files:      %d\n", pySum.TotalTestFiles)
-		fmt.Printf("Test Targets:    %d\n", pySum.TotalTestTargets)
+	if proto := report.Proto; proto != nil {
+		fmt.Println("\n--- Protobuf ---")
+		fmt.Printf("Packages:        %d (%d .proto files)\n", proto.TotalPackages, proto.TotalProtoFiles)
+		fmt.Printf("proto_library:   %.1f%% (%d/%d packages)\n",
+			proto.ProtoLibraryPct, proto.PackagesWithLibrary, proto.TotalPackages)
+		langs := make([]string, 0, len(proto.Bindings))
+		for lang := range proto.Bindings {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for _, lang := range langs {
+			fmt.Printf("  %-14s %d packages with Bazel bindings\n", lang+":", proto.Bindings[lang])
+		}
+		if n := len(proto.GeneratedOutsideBazel); n > 0 {
+			fmt.Printf("Generated outside Bazel: %d packages\n", n)
+			for i, pkg := range proto.GeneratedOutsideBazel {
+				if i >= 10 {
+					fmt.Printf("  ... and %d more\n", n-i)
+					break
+				}
+				langs := make([]string, 0, len(pkg.GeneratedOutsideBazel))
+				for _, lang := range pkg.GeneratedOutsideBazel {
+					langs = append(langs, string(lang))
+				}
+				fmt.Printf("  %s (%s)\n", pkg.RelPath, strings.Join(langs, ", "))
+			}
+		}
 	}
 
-	if rustSum, ok := report.LanguageSummaries["rust"]; ok {
-		fmt.Println("\n--- Rust ---")
-		fmt.Printf("Packages:        %d\n", rustSum.TotalPackages)
-		fmt.Printf("Bazelization:    %.1f%% (%d/%d packages have BUILD files)\n",
-			rustSum.BazelizationPct, rustSum.PackagesWithBuild, rustSum.TotalPackages)
-		fmt.Printf("Test Coverage:   %.1f%% (%d/%d packages have rust_test targets)\n",
-			rustSum.TestCoveragePct, rustSum.PackagesWithTests, rustSum.TotalPackages)
-		fmt.Printf("Source Files:    %d\n", rustSum.TotalSourceFiles)
-		fmt.Printf("Test Targets:    %d\n", rustSum.TotalTestTargets)
+	if bzlmod := report.Bzlmod; bzlmod != nil {
+		fmt.Println("\n--- Bzlmod Migration ---")
+		if bzlmod.BazelVersion != "" {
+			fmt.Printf("Bazel Version:   %s\n", bzlmod.BazelVersion)
+		}
+		fmt.Printf("Files:           MODULE.bazel %s, WORKSPACE %s\n", yesNo(bzlmod.HasModule), yesNo(bzlmod.HasWorkspace))
+		fmt.Printf("Migration:       %.1f%% (%d/%d external repos defined in MODULE.bazel)\n",
+			bzlmod.MigrationPct, bzlmod.BzlmodRepos, bzlmod.TotalRepos)
+		kinds := make([]string, 0, len(bzlmod.ByKind))
+		for kind := range bzlmod.ByKind {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Printf("  %-14s %d repos\n", kind+":", bzlmod.ByKind[kind])
+		}
+		for _, ruleSet := range []string{"rules_go", "rules_python", "rules_rust"} {
+			if v, ok := bzlmod.RulesVersions[ruleSet]; ok {
+				fmt.Printf("%-16s %s\n", ruleSet+":", v)
+			}
+		}
+	}
+
+	if len(report.Workspaces) > 1 {
+		fmt.Println("\n--- Workspaces ---")
+		for _, ws := range report.Workspaces {
+			name := "(root)"
+			if ws.Name != "" {
+				name = "@" + ws.Name
+			}
+			fmt.Printf("  %-20s %-16s %4d pkgs, %.1f%% bazelized, %d BUILD packages\n",
+				ws.RelPath, name, ws.TotalPackages, ws.BazelizationPct, ws.BuildPackages)
+		}
+	}
+
+	if hygiene := report.BuildHygiene; hygiene.TotalPackages > 0 {
+		fmt.Println("\n--- BUILD Hygiene ---")
+		fmt.Printf("Stale Packages:  %d/%d Bazel packages\n", hygiene.StalePackages, hygiene.TotalPackages)
+		fmt.Printf("Missing Files:   %d srcs/hdrs/data entries point at files that do not exist\n", hygiene.MissingFiles)
+		fmt.Printf("Unlisted Files:  %d files are not listed by any rule of their package\n", hygiene.UnlistedFiles)
+		for i, sb := range hygiene.Packages {
+			if i >= 10 {
+				fmt.Printf("  ... and %d more\n", len(hygiene.Packages)-i)
+				break
+			}
+			fmt.Printf("  %s (%d missing, %d unlisted)\n", sb.RelPath, len(sb.MissingFiles), len(sb.UnlistedFiles))
+		}
 	}
 
 	// Print top directories (Go only)
@@ -118,7 +312,7 @@ func main() {
 	}
 
 	// Run benchmarks if requested (Go only for now)
-	if runBenchmarks && len(scanResult.GoPackages) > 0 {
+	if runBenchmarks && len(scanResult.Packages(scanner.LangGo)) > 0 {
 		fmt.Println("\n=== Running Speed Benchmarks (Go) ===")
 		fmt.Printf("This may take several minutes...\n")
 
@@ -142,21 +336,17 @@ func main() {
 	// Write output
 	fmt.Printf("\nWriting metrics to %s...\n", outputPath)
 
-	var jsonBytes []byte
-	if prettyPrint {
-		jsonBytes, err = json.MarshalIndent(report, "", "  ")
-	} else {
-		jsonBytes, err = json.Marshal(report)
-	}
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "JSON marshal error: %v\n", err)
-		os.Exit(1)
-	}
-
-	if err := os.WriteFile(outputPath, jsonBytes, 0644); err != nil {
+	if err := writeJSON(outputPath, report, prettyPrint); err != nil {
 		fmt.Fprintf(os.Stderr, "Write error: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Println("Done!")
 }
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
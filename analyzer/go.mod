@@ -1,3 +1,7 @@
 module bazel-metrics/analyzer
 
 go 1.21
+
+require github.com/bazelbuild/buildtools v0.0.0-20260904073137-eaa4d125b423
+
+require golang.org/x/mod v0.20.0
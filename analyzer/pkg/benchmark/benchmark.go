@@ -66,7 +66,7 @@ func (r *Runner) Run() (*metrics.SpeedReport, error) {
 func (r *Runner) selectCandidates() []*scanner.Package {
 	var candidates []*scanner.Package
 
-	for _, pkg := range r.scanResult.GoPackages {
+	for _, pkg := range r.scanResult.Packages(scanner.LangGo) {
 		// Package must have test files and go_test targets
 		if pkg.HasTestFiles && pkg.TestTargetCount > 0 && pkg.TestFileCount > 0 && pkg.TestFileCount <= 20 {
 			candidates = append(candidates, pkg)
@@ -90,7 +90,8 @@ func (r *Runner) selectCandidates() []*scanner.Package {
 
 func (r *Runner) benchmarkPackage(pkg *scanner.Package) (*metrics.PackageBenchmark, error) {
 	benchmark := &metrics.PackageBenchmark{
-		Path: pkg.RelPath,
+		Path:      pkg.RelPath,
+		Workspace: pkg.Workspace,
 	}
 
 	// Benchmark go test
@@ -101,7 +102,7 @@ func (r *Runner) benchmarkPackage(pkg *scanner.Package) (*metrics.PackageBenchma
 	benchmark.GoTestMs = goTestTime
 
 	// Clean bazel cache for cold run
-	r.cleanBazelCache()
+	r.cleanBazelCache(pkg)
 
 	// Benchmark bazel test (cold)
 	bazelColdTime, err := r.runBazelTest(pkg)
@@ -167,13 +168,13 @@ func (r *Runner) findGoModDir(pkgPath string) string {
 
 func (r *Runner) runBazelTest(pkg *scanner.Package) (int64, error) {
 	// Convert path to bazel target
-	target := "//" + pkg.RelPath + ":all"
+	dir, target := r.bazelTarget(pkg)
 
 	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "bazel", "test", target, "--test_output=errors")
-	cmd.Dir = r.repoPath
+	cmd.Dir = dir
 
 	start := time.Now()
 	err := cmd.Run()
@@ -183,12 +184,31 @@ func (r *Runner) runBazelTest(pkg *scanner.Package) (int64, error) {
 	return elapsed, err
 }
 
-func (r *Runner) cleanBazelCache() {
+// bazelTarget returns the root of the package's Bazel workspace, where bazel
+// has to run, and the pattern matching the package's targets from there.
+// Packages of nested workspaces are not visible from the repository root.
+func (r *Runner) bazelTarget(pkg *scanner.Package) (string, string) {
+	rel, err := filepath.Rel(pkg.Workspace, pkg.RelPath)
+	if err != nil {
+		rel = pkg.RelPath
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	return filepath.Join(r.repoPath, pkg.Workspace), "//" + rel + ":all"
+}
+
+// cleanBazelCache cleans the output base of the package's workspace; each
+// workspace has its own
+func (r *Runner) cleanBazelCache(pkg *scanner.Package) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
+	dir, _ := r.bazelTarget(pkg)
+
 	// Use synchronous clean to ensure cache is fully cleared before benchmark
 	cmd := exec.CommandContext(ctx, "bazel", "clean")
-	cmd.Dir = r.repoPath
+	cmd.Dir = dir
 	cmd.Run() // Ignore errors
 }
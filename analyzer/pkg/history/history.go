@@ -0,0 +1,127 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is a commit picked for a point of the history
+type Commit struct {
+	Hash string
+	// Time is the commit time, in UTC
+	Time time.Time
+}
+
+// Sample picks the commits to scan for a history of ref from since to
+// until: at since, then every interval, and finally at until, the last
+// commit of ref's first-parent history made at or before that time. Points
+// before the first commit, and points where no new commit was made, are
+// skipped.
+func Sample(ctx context.Context, repoPath, ref string, since, until time.Time, interval time.Duration) ([]Commit, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	if until.Before(since) {
+		return nil, fmt.Errorf("until (%s) is before since (%s)", until.Format(time.DateOnly), since.Format(time.DateOnly))
+	}
+	commits, err := firstParentLog(ctx, repoPath, ref, until)
+	if err != nil {
+		return nil, err
+	}
+
+	var sampled []Commit
+	for t := since; ; t = t.Add(interval) {
+		// Commits made since the last full interval are sampled at until
+		if t.After(until) {
+			t = until
+		}
+		if c, ok := lastCommitAt(commits, t); ok {
+			if n := len(sampled); n == 0 || sampled[n-1].Hash != c.Hash {
+				sampled = append(sampled, c)
+			}
+		}
+		if !t.Before(until) {
+			break
+		}
+	}
+	return sampled, nil
+}
+
+// lastCommitAt returns the newest of the commits, newest first, made at or
+// before t
+func lastCommitAt(commits []Commit, t time.Time) (Commit, bool) {
+	for _, c := range commits {
+		if !c.Time.After(t) {
+			return c, true
+		}
+	}
+	return Commit{}, false
+}
+
+// firstParentLog lists ref's first-parent history up to until, newest
+// first. Following first parents keeps to the commits that were the tip of
+// the branch, rather than those of merged branches.
+func firstParentLog(ctx context.Context, repoPath, ref string, until time.Time) ([]Commit, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "log", "--first-parent",
+		"--format=%H %ct", "--until="+until.Format(time.RFC3339), ref, "--")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git log: %s", msg)
+		}
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		hash, ts, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+		commits = append(commits, Commit{Hash: hash, Time: time.Unix(sec, 0).UTC()})
+	}
+	return commits, nil
+}
+
+// ParseInterval parses a sampling interval: a number of days or weeks such
+// as "1d" or "2w", or a Go duration such as "12h"
+func ParseInterval(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("invalid interval %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid interval %q (want e.g. 1d, 1w or 12h)", s)
+	}
+	return d, nil
+}
+
+// ParseDate parses a date such as "2024-01-31", meaning midnight UTC, or an
+// RFC 3339 time
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD or RFC 3339)", s)
+	}
+	return t.UTC(), nil
+}
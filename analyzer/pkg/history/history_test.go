@@ -0,0 +1,96 @@
+package history
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitRepo creates a repository with an empty commit at each of the times,
+// returning its path and the commit hashes
+func gitRepo(t *testing.T, times ...time.Time) (string, []string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(env []string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git(nil, "init", "-q", "-b", "main")
+	var hashes []string
+	for _, at := range times {
+		date := at.Format(time.RFC3339)
+		git([]string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date},
+			"-c", "user.name=test", "-c", "user.email=test@example.com",
+			"commit", "-q", "--allow-empty", "-m", date)
+		hashes = append(hashes, git(nil, "rev-parse", "HEAD"))
+	}
+	return dir, hashes
+}
+
+func TestSample(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2024, 1, d, h, 0, 0, 0, time.UTC) }
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name         string
+		commits      []time.Time
+		since, until time.Time
+		want         []int // indexes of the sampled commits
+	}{
+		{
+			name:    "one commit per interval",
+			commits: []time.Time{day(1, 6), day(2, 6), day(3, 6)},
+			since:   day(2, 0), until: day(4, 0),
+			want: []int{0, 1, 2},
+		},
+		{
+			name:    "repeated commits are skipped",
+			commits: []time.Time{day(1, 6)},
+			since:   day(1, 0), until: day(4, 0),
+			want: []int{0},
+		},
+		{
+			name:    "commit within the last interval",
+			commits: []time.Time{day(1, 6), day(2, 6), day(4, 11)},
+			since:   day(1, 0), until: day(4, 12),
+			want: []int{0, 1, 2},
+		},
+		{
+			name:    "fresh repository",
+			commits: []time.Time{now.Add(-5 * time.Minute)},
+			since:   now.Add(-7*24*time.Hour + time.Hour), until: now,
+			want: []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, hashes := gitRepo(t, tt.commits...)
+			got, err := Sample(context.Background(), repo, "main", tt.since, tt.until, 24*time.Hour)
+			if err != nil {
+				t.Fatalf("Sample: %v", err)
+			}
+			var gotHashes, want []string
+			for _, c := range got {
+				gotHashes = append(gotHashes, c.Hash)
+			}
+			for _, i := range tt.want {
+				want = append(want, hashes[i])
+			}
+			if strings.Join(gotHashes, " ") != strings.Join(want, " ") {
+				t.Errorf("Sample = %v, want %v", gotHashes, want)
+			}
+		})
+	}
+}
@@ -21,6 +21,21 @@ type LanguageSummary struct {
 	PackagesWithBuild int     `json:"packagesWithBuild"`
 	PackagesWithTests int     `json:"packagesWithTests"`
 	TotalTestTargets  int     `json:"totalTestTargets"`
+	// BazelizedTestFilesPct is the share of test files listed in the srcs
+	// of a test target
+	BazelizedTestFilesPct float64 `json:"bazelizedTestFilesPct"`
+	UncoveredTestFiles    int     `json:"uncoveredTestFiles"`
+	UncoveredSourceFiles  int     `json:"uncoveredSourceFiles"`
+	// OwnedPct counts packages with a BUILD file and packages without one
+	// whose files an ancestor Bazel package builds
+	OwnedPct                float64 `json:"ownedPct"`
+	PackagesOwnedByAncestor int     `json:"packagesOwnedByAncestor"`
+	UnownedPackages         int     `json:"unownedPackages"`
+	// TotalGeneratedFiles counts generated source files, which
+	// TotalSourceFiles leaves out, and GeneratedOnlyPackages the packages
+	// with nothing but generated files
+	TotalGeneratedFiles   int `json:"totalGeneratedFiles"`
+	GeneratedOnlyPackages int `json:"generatedOnlyPackages"`
 }
 
 // Summary contains high-level metrics (kept for backwards compatibility)
@@ -49,30 +64,200 @@ type DirectoryMetrics struct {
 
 // PackageInfo is the simplified package info for output
 type PackageInfo struct {
-	Path             string `json:"path"`
-	Language         string `json:"language,omitempty"`
-	HasBuildFile     bool   `json:"hasBuildFile"`
-	HasTestFiles     bool   `json:"hasTestFiles"`
-	TestFileCount    int    `json:"testFileCount"`
-	TestTargetCount  int    `json:"goTestTargetCount"` // kept as goTestTargetCount for backwards compat
-	SourceFileCount  int    `json:"goFileCount"`       // kept as goFileCount for backwards compat
+	Path            string `json:"path"`
+	Language        string `json:"language,omitempty"`
+	HasBuildFile    bool   `json:"hasBuildFile"`
+	HasTestFiles    bool   `json:"hasTestFiles"`
+	TestFileCount   int    `json:"testFileCount"`
+	TestTargetCount int    `json:"goTestTargetCount"` // kept as goTestTargetCount for backwards compat
+	SourceFileCount int    `json:"goFileCount"`       // kept as goFileCount for backwards compat
+	LibraryTargets  int    `json:"libraryTargetCount"`
+	BinaryTargets   int    `json:"binaryTargetCount"`
+	ModulePath      string `json:"modulePath,omitempty"`
+	ImportPath      string `json:"importPath,omitempty"`
+	// GazelleImportPath and GoNamingConvention come from gazelle directives
+	GazelleImportPath  string `json:"gazelleImportPath,omitempty"`
+	GoNamingConvention string `json:"goNamingConvention,omitempty"`
+	// SupportFileCount counts files that are neither sources nor tests
+	SupportFileCount int `json:"supportFileCount,omitempty"`
+	// GeneratedFileCount counts generated source files, which
+	// SourceFileCount leaves out
+	GeneratedFileCount int `json:"generatedFileCount,omitempty"`
+	// CrateName and CargoWorkspace identify a Rust crate
+	CrateName      string `json:"crateName,omitempty"`
+	CargoWorkspace string `json:"cargoWorkspace,omitempty"`
+	// BuildTool is "maven" or "gradle" for a JVM module
+	BuildTool string `json:"buildTool,omitempty"`
+	// NpmPackage is the package.json name of a TypeScript package
+	NpmPackage string `json:"npmPackage,omitempty"`
+	// Targets are the rules of the package's BUILD files, of any language
+	Targets []*scanner.Target `json:"targets"`
+	// BazelPackage is the label of the owning Bazel package, and Ownership
+	// is "build", "ancestor" or "unowned"
+	BazelPackage string `json:"bazelPackage,omitempty"`
+	Ownership    string `json:"ownership"`
+	// Workspace is the relPath of the package's Bazel workspace
+	Workspace string `json:"workspace"`
+	// Files not listed in the srcs of any (test) target
+	UncoveredTestFiles   []string `json:"uncoveredTestFiles,omitempty"`
+	UncoveredSourceFiles []string `json:"uncoveredSourceFiles,omitempty"`
+}
+
+// ExclusionSummary describes packages left out of the scan by ignore rules
+// and language toolchain rules
+type ExclusionSummary struct {
+	TotalPackages int                        `json:"totalPackages"`
+	ByReason      map[string]int             `json:"byReason"`
+	Packages      []*scanner.ExcludedPackage `json:"packages"`
+	// ByDirective lists the packages excluded by gazelle directives
+	ByDirective []*scanner.ExcludedPackage `json:"byDirective"`
+}
+
+// ProtoSummary describes how .proto packages are built
+type ProtoSummary struct {
+	TotalPackages   int `json:"totalPackages"`
+	TotalProtoFiles int `json:"totalProtoFiles"`
+	// PackagesWithLibrary counts packages with a proto_library
+	PackagesWithLibrary int     `json:"packagesWithLibrary"`
+	ProtoLibraryPct     float64 `json:"protoLibraryPct"`
+	// Bindings counts packages with a Bazel binding, per language
+	Bindings map[string]int `json:"bindings"`
+	// GeneratedOutsideBazel lists packages with checked-in generated code
+	// for a language that has no Bazel binding
+	GeneratedOutsideBazel []*scanner.ProtoPackage `json:"generatedOutsideBazel"`
+	Packages              []*scanner.ProtoPackage `json:"packages"`
+}
+
+// BuildHygieneSummary describes BUILD files that are out of date with the
+// files on disk
+type BuildHygieneSummary struct {
+	// TotalPackages counts Bazel packages, i.e. directories with a BUILD file
+	TotalPackages int `json:"totalPackages"`
+	StalePackages int `json:"stalePackages"`
+	// MissingFiles and UnlistedFiles total the findings of all packages
+	MissingFiles  int                   `json:"missingFiles"`
+	UnlistedFiles int                   `json:"unlistedFiles"`
+	Packages      []*scanner.StaleBuild `json:"packages"`
+}
+
+// BzlmodSummary describes the migration of external repositories from
+// WORKSPACE to MODULE.bazel
+type BzlmodSummary struct {
+	BazelVersion  string `json:"bazelVersion,omitempty"`
+	HasModule     bool   `json:"hasModule"`
+	HasWorkspace  bool   `json:"hasWorkspace"`
+	ModuleName    string `json:"moduleName,omitempty"`
+	ModuleVersion string `json:"moduleVersion,omitempty"`
+	// TotalRepos counts distinct repository names; BzlmodRepos those defined
+	// in MODULE.bazel, and WorkspaceOnlyRepos those only WORKSPACE defines
+	TotalRepos         int     `json:"totalRepos"`
+	BzlmodRepos        int     `json:"bzlmodRepos"`
+	WorkspaceOnlyRepos int     `json:"workspaceOnlyRepos"`
+	MigrationPct       float64 `json:"migrationPct"`
+	// ByKind counts repositories per bazel_dep, use_repo or repository rule
+	ByKind        map[string]int    `json:"byKind"`
+	RulesVersions map[string]string `json:"rulesVersions"`
+	// LegacyRepos are the WORKSPACE repositories still to migrate
+	LegacyRepos []*scanner.ExternalRepo `json:"legacyRepos"`
+	Repos       []*scanner.ExternalRepo `json:"repos"`
+}
+
+// WorkspaceSummary describes one Bazel workspace of the repository: the
+// root, or a nested workspace such as an example project
+type WorkspaceSummary struct {
+	RelPath string `json:"relPath"`
+	// Name is the repository name in the labels of the workspace's packages,
+	// empty for the root workspace
+	Name            string `json:"name,omitempty"`
+	Parent          string `json:"parent,omitempty"`
+	LocalRepository bool   `json:"localRepository,omitempty"`
+	// BuildPackages counts the workspace's directories with a BUILD file
+	BuildPackages int `json:"buildPackages"`
+	// TotalPackages and PackagesWithBuild total the language packages of
+	// every language
+	TotalPackages     int                         `json:"totalPackages"`
+	PackagesWithBuild int                         `json:"packagesWithBuild"`
+	BazelizationPct   float64                     `json:"bazelizationPct"`
+	Languages         []string                    `json:"languages"`
+	LanguageSummaries map[string]*LanguageSummary `json:"languageSummaries"`
+	// Bzlmod describes the workspace's own MODULE.bazel and WORKSPACE
+	Bzlmod *BzlmodSummary `json:"bzlmod,omitempty"`
 }
 
 // Report is the complete metrics report
 type Report struct {
-	Timestamp          string              `json:"timestamp"`
-	RepoPath           string              `json:"repoPath"`
+	Timestamp string `json:"timestamp"`
+	RepoPath  string `json:"repoPath"`
+	// Commit is the git commit scanned, set for reports of past commits;
+	// Timestamp is then the commit time
+	Commit             string              `json:"commit,omitempty"`
 	Summary            Summary             `json:"summary"`
 	DirectoryBreakdown []*DirectoryMetrics `json:"directoryBreakdown"`
 	Packages           []*PackageInfo      `json:"packages"`
 	SpeedComparison    *SpeedReport        `json:"speedComparison,omitempty"`
 
+	// ExcludeGenerated is set when generated-only packages are left out of
+	// the package totals and percentages
+	ExcludeGenerated bool `json:"excludeGenerated,omitempty"`
+
 	// Multi-language support
-	Languages        []string                    `json:"languages"`
+	Languages         []string                    `json:"languages"`
+	LanguageSummaries map[string]*LanguageSummary `json:"languageSummaries"`
+	LanguagePackages  map[string][]*PackageInfo   `json:"languagePackages"`
+
+	// Per-language package lists from before languagePackages, still read by
+	// older dashboards
+	GoPackages     []*PackageInfo `json:"goPackages,omitempty"`
+	PythonPackages []*PackageInfo `json:"pythonPackages,omitempty"`
+	RustPackages   []*PackageInfo `json:"rustPackages,omitempty"`
+
+	Exclusions *ExclusionSummary `json:"exclusions"`
+
+	// Targets indexes every target in the repo by label
+	Targets []*scanner.Target `json:"targets"`
+
+	// Proto coverage, when the repo has .proto files
+	Proto *ProtoSummary `json:"proto,omitempty"`
+
+	// The Bazel package tree, with the source directories each package owns
+	BazelPackages []*scanner.BazelPackage `json:"bazelPackages"`
+
+	// WORKSPACE to bzlmod migration, when the repo has either file
+	Bzlmod *BzlmodSummary `json:"bzlmod,omitempty"`
+
+	// The root workspace followed by any nested ones
+	Workspaces []*WorkspaceSummary `json:"workspaces"`
+
+	// Stale BUILD files
+	BuildHygiene *BuildHygieneSummary `json:"buildHygiene"`
+
+	// Scan cache hit/miss counts, when the cache was enabled
+	ScanCache *scanner.CacheStats `json:"scanCache,omitempty"`
+}
+
+// History is a series of metrics for past commits, oldest first, for
+// trend charts
+type History struct {
+	RepoPath string          `json:"repoPath"`
+	Ref      string          `json:"ref"`
+	Interval string          `json:"interval"`
+	Points   []*HistoryPoint `json:"points"`
+}
+
+// HistoryPoint holds the headline metrics of one commit's report
+type HistoryPoint struct {
+	Timestamp         string                      `json:"timestamp"`
+	Commit            string                      `json:"commit"`
+	Summary           Summary                     `json:"summary"`
+	Languages         []string                    `json:"languages"`
 	LanguageSummaries map[string]*LanguageSummary `json:"languageSummaries"`
-	GoPackages       []*PackageInfo              `json:"goPackages,omitempty"`
-	PythonPackages   []*PackageInfo              `json:"pythonPackages,omitempty"`
-	RustPackages     []*PackageInfo              `json:"rustPackages,omitempty"`
+	// BuildPackages counts directories with a BUILD file, and StalePackages
+	// those whose BUILD file is out of date with their files
+	BuildPackages int `json:"buildPackages"`
+	StalePackages int `json:"stalePackages"`
+	// BzlmodMigrationPct is nil while the repo has neither MODULE.bazel nor
+	// WORKSPACE
+	BzlmodMigrationPct *float64 `json:"bzlmodMigrationPct,omitempty"`
 }
 
 // SpeedReport contains benchmark comparison data
@@ -86,16 +271,38 @@ type PackageBenchmark struct {
 	GoTestMs        int64  `json:"goTestMs"`
 	BazelTestColdMs int64  `json:"bazelTestColdMs"`
 	BazelTestWarmMs int64  `json:"bazelTestWarmMs"`
+	// Workspace is the relPath of the Bazel workspace bazel test ran in
+	Workspace string `json:"workspace,omitempty"`
 }
 
 // Calculator computes metrics from scan results
 type Calculator struct {
 	scanResult *scanner.ScanResult
+	// excludeGenerated leaves generated-only packages out of the package
+	// totals and percentages
+	excludeGenerated bool
+}
+
+// Option configures a Calculator
+type Option func(*Calculator)
+
+// WithExcludeGenerated leaves packages whose source files are all generated,
+// and that have no tests, out of the package totals and the bazelization
+// and test percentages. They are still listed and counted as
+// generatedOnlyPackages.
+func WithExcludeGenerated(exclude bool) Option {
+	return func(c *Calculator) {
+		c.excludeGenerated = exclude
+	}
 }
 
 // NewCalculator creates a new metrics calculator
-func NewCalculator(result *scanner.ScanResult) *Calculator {
-	return &Calculator{scanResult: result}
+func NewCalculator(result *scanner.ScanResult, opts ...Option) *Calculator {
+	c := &Calculator{scanResult: result}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Calculate computes all metrics and returns a report
@@ -106,105 +313,280 @@ func (c *Calculator) Calculate() *Report {
 		Packages:          make([]*PackageInfo, 0),
 		Languages:         make([]string, 0),
 		LanguageSummaries: make(map[string]*LanguageSummary),
+		LanguagePackages:  make(map[string][]*PackageInfo),
+		ExcludeGenerated:  c.excludeGenerated,
 	}
 
-	// Calculate Go metrics
-	if len(c.scanResult.GoPackages) > 0 {
-		report.Languages = append(report.Languages, "go")
-		goSummary := c.calculateLanguageSummary("go", c.scanResult.GoPackages)
-		report.LanguageSummaries["go"] = goSummary
-
-		goPackages := make([]*PackageInfo, 0, len(c.scanResult.GoPackages))
-		for _, pkg := range c.scanResult.GoPackages {
-			pi := &PackageInfo{
-				Path:            pkg.RelPath,
-				Language:        "go",
-				HasBuildFile:    pkg.HasBuildFile,
-				HasTestFiles:    pkg.HasTestFiles,
-				TestFileCount:   pkg.TestFileCount,
-				TestTargetCount: pkg.TestTargetCount,
-				SourceFileCount: pkg.SourceFileCount,
-			}
-			goPackages = append(goPackages, pi)
-			report.Packages = append(report.Packages, pi)
+	for _, lr := range c.scanResult.Languages {
+		if len(lr.Packages) == 0 {
+			continue
 		}
-		report.GoPackages = goPackages
+		lang := string(lr.Language)
+		report.Languages = append(report.Languages, lang)
+		summary := c.calculateLanguageSummary(lang, lr.Packages)
+		report.LanguageSummaries[lang] = summary
+
+		packages := make([]*PackageInfo, 0, len(lr.Packages))
+		for _, pkg := range lr.Packages {
+			packages = append(packages, newPackageInfo(pkg))
+		}
+		report.LanguagePackages[lang] = packages
+
+		switch lr.Language {
+		case scanner.LangPython:
+			report.PythonPackages = packages
+		case scanner.LangRust:
+			report.RustPackages = packages
+		}
+		if lr.Language != scanner.LangGo {
+			continue
+		}
+		report.Packages = packages
+		report.GoPackages = packages
 
 		// Backwards compatible summary (Go-only)
 		report.Summary = Summary{
-			BazelizationPct:    goSummary.BazelizationPct,
-			TestCoveragePct:    goSummary.TestCoveragePct,
-			BazelizedTestsPct:  goSummary.BazelizedTestsPct,
-			TotalPackages:      goSummary.TotalPackages,
+			BazelizationPct:    summary.BazelizationPct,
+			TestCoveragePct:    summary.TestCoveragePct,
+			BazelizedTestsPct:  summary.BazelizedTestsPct,
+			TotalPackages:      summary.TotalPackages,
 			TotalBuildFiles:    c.scanResult.TotalBUILDs,
-			TotalTestFiles:     goSummary.TotalTestFiles,
-			TotalGoFiles:       goSummary.TotalSourceFiles,
-			PackagesWithBuild:  goSummary.PackagesWithBuild,
-			PackagesWithTests:  goSummary.PackagesWithTests,
-			TotalGoTestTargets: goSummary.TotalTestTargets,
+			TotalTestFiles:     summary.TotalTestFiles,
+			TotalGoFiles:       summary.TotalSourceFiles,
+			PackagesWithBuild:  summary.PackagesWithBuild,
+			PackagesWithTests:  summary.PackagesWithTests,
+			TotalGoTestTargets: summary.TotalTestTargets,
 		}
 	}
 
-	// Calculate Python metrics
-	if len(c.scanResult.PythonPackages) > 0 {
-		report.Languages = append(report.Languages, "python")
-		pySummary := c.calculateLanguageSummary("python", c.scanResult.PythonPackages)
-		report.LanguageSummaries["python"] = pySummary
-
-		pyPackages := make([]*PackageInfo, 0, len(c.scanResult.PythonPackages))
-		for _, pkg := range c.scanResult.PythonPackages {
-			pi := &PackageInfo{
-				Path:            pkg.RelPath,
-				Language:        "python",
-				HasBuildFile:    pkg.HasBuildFile,
-				HasTestFiles:    pkg.HasTestFiles,
-				TestFileCount:   pkg.TestFileCount,
-				TestTargetCount: pkg.TestTargetCount,
-				SourceFileCount: pkg.SourceFileCount,
-			}
-			pyPackages = append(pyPackages, pi)
+	// Calculate directory breakdown (Go only for backwards compat)
+	report.DirectoryBreakdown = c.calculateDirectoryBreakdown(c.countedPackages(c.scanResult.Packages(scanner.LangGo)))
+
+	report.Exclusions = c.calculateExclusions()
+	report.Proto = c.calculateProto()
+	report.BuildHygiene = c.calculateBuildHygiene()
+	report.Bzlmod = calculateBzlmod(c.scanResult.External)
+	report.Workspaces = c.calculateWorkspaces()
+	report.BazelPackages = c.scanResult.BazelPackages
+	report.Targets = c.scanResult.Targets
+	report.ScanCache = c.scanResult.Cache
+
+	return report
+}
+
+func newPackageInfo(pkg *scanner.Package) *PackageInfo {
+	info := &PackageInfo{
+		Path:               pkg.RelPath,
+		Language:           string(pkg.Language),
+		HasBuildFile:       pkg.HasBuildFile,
+		HasTestFiles:       pkg.HasTestFiles,
+		TestFileCount:      pkg.TestFileCount,
+		TestTargetCount:    pkg.TestTargetCount,
+		SourceFileCount:    pkg.SourceFileCount,
+		GeneratedFileCount: pkg.GeneratedFileCount,
+		LibraryTargets:     pkg.LibraryTargets,
+		BinaryTargets:      pkg.BinaryTargets,
+		ModulePath:         pkg.ModulePath,
+		ImportPath:         pkg.ImportPath,
+		GazelleImportPath:  pkg.GazelleImportPath,
+		GoNamingConvention: pkg.GoNamingConvention,
+		SupportFileCount:   pkg.SupportFileCount,
+		CrateName:          pkg.CrateName,
+		CargoWorkspace:     pkg.CargoWorkspace,
+		BuildTool:          pkg.BuildTool,
+		NpmPackage:         pkg.NpmPackage,
+		Targets:            pkg.Targets,
+		BazelPackage:       pkg.BazelPackage,
+		Ownership:          string(pkg.Ownership),
+		Workspace:          pkg.Workspace,
+
+		UncoveredTestFiles:   pkg.UncoveredTestFiles,
+		UncoveredSourceFiles: pkg.UncoveredSourceFiles,
+	}
+	if info.Targets == nil {
+		info.Targets = make([]*scanner.Target, 0)
+	}
+	return info
+}
+
+func (c *Calculator) calculateExclusions() *ExclusionSummary {
+	summary := &ExclusionSummary{
+		TotalPackages: len(c.scanResult.ExcludedPackages),
+		ByReason:      make(map[string]int),
+		Packages:      c.scanResult.ExcludedPackages,
+		ByDirective:   make([]*scanner.ExcludedPackage, 0),
+	}
+	for _, pkg := range c.scanResult.ExcludedPackages {
+		summary.ByReason[string(pkg.Reason)]++
+		if pkg.Reason == scanner.ExcludedByGazelle || pkg.Reason == scanner.ExcludedByGazelleIgnore {
+			summary.ByDirective = append(summary.ByDirective, pkg)
+		}
+	}
+	return summary
+}
+
+func (c *Calculator) calculateProto() *ProtoSummary {
+	if len(c.scanResult.ProtoPackages) == 0 {
+		return nil
+	}
+	summary := &ProtoSummary{
+		TotalPackages:         len(c.scanResult.ProtoPackages),
+		Bindings:              make(map[string]int),
+		GeneratedOutsideBazel: make([]*scanner.ProtoPackage, 0),
+		Packages:              c.scanResult.ProtoPackages,
+	}
+	for _, pkg := range c.scanResult.ProtoPackages {
+		summary.TotalProtoFiles += pkg.ProtoFileCount
+		if pkg.HasProtoLibrary {
+			summary.PackagesWithLibrary++
+		}
+		for _, lang := range pkg.Bindings {
+			summary.Bindings[string(lang)]++
+		}
+		if len(pkg.GeneratedOutsideBazel) > 0 {
+			summary.GeneratedOutsideBazel = append(summary.GeneratedOutsideBazel, pkg)
+		}
+	}
+	summary.ProtoLibraryPct = float64(summary.PackagesWithLibrary) / float64(summary.TotalPackages) * 100
+	return summary
+}
+
+func (c *Calculator) calculateBuildHygiene() *BuildHygieneSummary {
+	summary := &BuildHygieneSummary{
+		TotalPackages: c.scanResult.BuildPackages,
+		StalePackages: len(c.scanResult.StaleBuilds),
+		Packages:      c.scanResult.StaleBuilds,
+	}
+	for _, sb := range c.scanResult.StaleBuilds {
+		summary.MissingFiles += len(sb.MissingFiles)
+		summary.UnlistedFiles += len(sb.UnlistedFiles)
+	}
+	return summary
+}
+
+func calculateBzlmod(ext *scanner.ExternalDeps) *BzlmodSummary {
+	if ext == nil {
+		return nil
+	}
+	summary := &BzlmodSummary{
+		BazelVersion:  ext.BazelVersion,
+		HasModule:     ext.ModuleFile != "",
+		HasWorkspace:  ext.WorkspaceFile != "",
+		ModuleName:    ext.ModuleName,
+		ModuleVersion: ext.ModuleVersion,
+		ByKind:        make(map[string]int),
+		RulesVersions: ext.RulesVersions,
+		LegacyRepos:   make([]*scanner.ExternalRepo, 0),
+		Repos:         ext.Repos,
+	}
+	bzlmod := make(map[string]bool)
+	for _, repo := range ext.Repos {
+		summary.ByKind[repo.Kind]++
+		if repo.Bzlmod {
+			bzlmod[repo.Name] = true
+		}
+	}
+	names := make(map[string]bool)
+	for _, repo := range ext.Repos {
+		names[repo.Name] = true
+		if !repo.Bzlmod && !bzlmod[repo.Name] {
+			summary.LegacyRepos = append(summary.LegacyRepos, repo)
+		}
+	}
+	summary.TotalRepos = len(names)
+	summary.BzlmodRepos = len(bzlmod)
+	summary.WorkspaceOnlyRepos = summary.TotalRepos - summary.BzlmodRepos
+	if summary.TotalRepos > 0 {
+		summary.MigrationPct = float64(summary.BzlmodRepos) / float64(summary.TotalRepos) * 100
+	}
+	return summary
+}
+
+func (c *Calculator) calculateWorkspaces() []*WorkspaceSummary {
+	summaries := make([]*WorkspaceSummary, 0, len(c.scanResult.Workspaces))
+	byPath := make(map[string]*WorkspaceSummary, len(c.scanResult.Workspaces))
+	for _, ws := range c.scanResult.Workspaces {
+		summary := &WorkspaceSummary{
+			RelPath:           ws.RelPath,
+			Name:              ws.Name,
+			Parent:            ws.Parent,
+			LocalRepository:   ws.LocalRepository,
+			Languages:         make([]string, 0),
+			LanguageSummaries: make(map[string]*LanguageSummary),
+			Bzlmod:            calculateBzlmod(ws.External),
 		}
-		report.PythonPackages = pyPackages
-	}
-
-	// Calculate Rust metrics
-	if len(c.scanResult.RustPackages) > 0 {
-		report.Languages = append(report.Languages, "rust")
-		rustSummary := c.calculateLanguageSummary("rust", c.scanResult.RustPackages)
-		report.LanguageSummaries["rust"] = rustSummary
-
-		rustPackages := make([]*PackageInfo, 0, len(c.scanResult.RustPackages))
-		for _, pkg := range c.scanResult.RustPackages {
-			pi := &PackageInfo{
-				Path:            pkg.RelPath,
-				Language:        "rust",
-				HasBuildFile:    pkg.HasBuildFile,
-				HasTestFiles:    pkg.HasTestFiles,
-				TestFileCount:   pkg.TestFileCount,
-				TestTargetCount: pkg.TestTargetCount,
-				SourceFileCount: pkg.SourceFileCount,
+		summaries = append(summaries, summary)
+		byPath[ws.RelPath] = summary
+	}
+	for _, pkg := range c.scanResult.BazelPackages {
+		if summary := byPath[pkg.Workspace]; summary != nil {
+			summary.BuildPackages++
+		}
+	}
+
+	for _, lr := range c.scanResult.Languages {
+		byWorkspace := make(map[string][]*scanner.Package)
+		for _, pkg := range lr.Packages {
+			byWorkspace[pkg.Workspace] = append(byWorkspace[pkg.Workspace], pkg)
+		}
+		for _, summary := range summaries {
+			packages := byWorkspace[summary.RelPath]
+			if len(packages) == 0 {
+				continue
 			}
-			rustPackages = append(rustPackages, pi)
+			lang := string(lr.Language)
+			ls := c.calculateLanguageSummary(lang, packages)
+			summary.Languages = append(summary.Languages, lang)
+			summary.LanguageSummaries[lang] = ls
+			summary.TotalPackages += ls.TotalPackages
+			summary.PackagesWithBuild += ls.PackagesWithBuild
+		}
+	}
+	for _, summary := range summaries {
+		if summary.TotalPackages > 0 {
+			summary.BazelizationPct = float64(summary.PackagesWithBuild) / float64(summary.TotalPackages) * 100
 		}
-		report.RustPackages = rustPackages
 	}
+	return summaries
+}
 
-	// Calculate directory breakdown (Go only for backwards compat)
-	report.DirectoryBreakdown = c.calculateDirectoryBreakdown(c.scanResult.GoPackages)
+// generatedOnly reports whether all of a package's files are generated
+func generatedOnly(pkg *scanner.Package) bool {
+	return pkg.GeneratedFileCount > 0 && pkg.SourceFileCount == 0 && pkg.TestFileCount == 0
+}
 
-	return report
+// countedPackages returns the packages that count towards the totals and
+// percentages, leaving out generated-only ones if so configured
+func (c *Calculator) countedPackages(packages []*scanner.Package) []*scanner.Package {
+	if !c.excludeGenerated {
+		return packages
+	}
+	counted := make([]*scanner.Package, 0, len(packages))
+	for _, pkg := range packages {
+		if !generatedOnly(pkg) {
+			counted = append(counted, pkg)
+		}
+	}
+	return counted
 }
 
 func (c *Calculator) calculateLanguageSummary(lang string, packages []*scanner.Package) *LanguageSummary {
-	summary := &LanguageSummary{
-		Language:      lang,
-		TotalPackages: len(packages),
+	summary := &LanguageSummary{Language: lang}
+	for _, pkg := range packages {
+		summary.TotalGeneratedFiles += pkg.GeneratedFileCount
+		if generatedOnly(pkg) {
+			summary.GeneratedOnlyPackages++
+		}
 	}
+	packages = c.countedPackages(packages)
+	summary.TotalPackages = len(packages)
 
 	for _, pkg := range packages {
 		summary.TotalSourceFiles += pkg.SourceFileCount
 		summary.TotalTestFiles += pkg.TestFileCount
 		summary.TotalTestTargets += pkg.TestTargetCount
+		summary.UncoveredTestFiles += len(pkg.UncoveredTestFiles)
+		summary.UncoveredSourceFiles += len(pkg.UncoveredSourceFiles)
 
 		if pkg.HasBuildFile {
 			summary.PackagesWithBuild++
@@ -212,12 +594,20 @@ func (c *Calculator) calculateLanguageSummary(lang string, packages []*scanner.P
 		if pkg.HasTestFiles {
 			summary.PackagesWithTests++
 		}
+		switch pkg.Ownership {
+		case scanner.OwnedByAncestor:
+			summary.PackagesOwnedByAncestor++
+		case scanner.Unowned:
+			summary.UnownedPackages++
+		}
 	}
 
 	// Calculate percentages
 	if summary.TotalPackages > 0 {
 		summary.BazelizationPct = float64(summary.PackagesWithBuild) / float64(summary.TotalPackages) * 100
 		summary.TestCoveragePct = float64(summary.PackagesWithTests) / float64(summary.TotalPackages) * 100
+		owned := summary.TotalPackages - summary.UnownedPackages
+		summary.OwnedPct = float64(owned) / float64(summary.TotalPackages) * 100
 	}
 
 	// Bazelized tests: packages with tests that also have test targets
@@ -231,6 +621,12 @@ func (c *Calculator) calculateLanguageSummary(lang string, packages []*scanner.P
 		summary.BazelizedTestsPct = float64(packagesWithBazelizedTests) / float64(summary.PackagesWithTests) * 100
 	}
 
+	// Bazelized test files: test files listed in a test target's srcs
+	if summary.TotalTestFiles > 0 {
+		covered := summary.TotalTestFiles - summary.UncoveredTestFiles
+		summary.BazelizedTestFilesPct = float64(covered) / float64(summary.TotalTestFiles) * 100
+	}
+
 	return summary
 }
 
@@ -269,9 +665,12 @@ func (c *Calculator) calculateDirectoryBreakdown(packages []*scanner.Package) []
 		result = append(result, dm)
 	}
 
-	// Sort by total packages descending
+	// Sort by total packages descending, then by name for deterministic output
 	sort.Slice(result, func(i, j int) bool {
-		return result[i].TotalPackages > result[j].TotalPackages
+		if result[i].TotalPackages != result[j].TotalPackages {
+			return result[i].TotalPackages > result[j].TotalPackages
+		}
+		return result[i].Name < result[j].Name
 	})
 
 	return result
@@ -291,3 +690,23 @@ func getTopLevelDir(path string) string {
 func (r *Report) SetSpeedComparison(speed *SpeedReport) {
 	r.SpeedComparison = speed
 }
+
+// HistoryPoint returns the report's headline metrics for a history
+func (r *Report) HistoryPoint() *HistoryPoint {
+	point := &HistoryPoint{
+		Timestamp:         r.Timestamp,
+		Commit:            r.Commit,
+		Summary:           r.Summary,
+		Languages:         r.Languages,
+		LanguageSummaries: r.LanguageSummaries,
+	}
+	if r.BuildHygiene != nil {
+		point.BuildPackages = r.BuildHygiene.TotalPackages
+		point.StalePackages = r.BuildHygiene.StalePackages
+	}
+	if r.Bzlmod != nil {
+		pct := r.Bzlmod.MigrationPct
+		point.BzlmodMigrationPct = &pct
+	}
+	return point
+}
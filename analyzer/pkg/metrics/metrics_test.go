@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+
+	"bazel-metrics/analyzer/pkg/scanner"
+)
+
+func TestReportKeepsLegacyPackageLists(t *testing.T) {
+	result := &scanner.ScanResult{RepoPath: "/repo"}
+	for _, lang := range []scanner.Language{scanner.LangGo, scanner.LangPython, scanner.LangRust, scanner.LangJava} {
+		result.Languages = append(result.Languages, &scanner.LanguageResult{
+			Language: lang,
+			Packages: []*scanner.Package{{RelPath: "pkg/" + string(lang), Language: lang, SourceFileCount: 1}},
+		})
+	}
+	data, err := json.Marshal(NewCalculator(result).Calculate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report map[string]json.RawMessage
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, lang := range map[string]scanner.Language{
+		"goPackages":     scanner.LangGo,
+		"pythonPackages": scanner.LangPython,
+		"rustPackages":   scanner.LangRust,
+	} {
+		var pkgs []PackageInfo
+		if err := json.Unmarshal(report[key], &pkgs); err != nil {
+			t.Errorf("%s: %v", key, err)
+			continue
+		}
+		if len(pkgs) != 1 || pkgs[0].Path != "pkg/"+string(lang) {
+			t.Errorf("%s = %+v, want the %s package", key, pkgs, lang)
+		}
+	}
+}
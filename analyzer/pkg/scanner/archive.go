@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// archiveFormats are the archive extensions OpenArchive understands
+var archiveFormats = []string{".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar", ".zip"}
+
+// IsArchive reports whether a file name has an archive extension OpenArchive
+// understands
+func IsArchive(filename string) bool {
+	return archiveFormat(filename) != ""
+}
+
+func archiveFormat(filename string) string {
+	lower := strings.ToLower(filename)
+	for _, ext := range archiveFormats {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// OpenArchive loads a tarball or zip archive of a source tree into memory
+// for scanning with WithFS. Archives whose entries all sit under a single
+// top-level directory, like GitHub source archives, are rooted at that
+// directory.
+func OpenArchive(filename string) (fs.FS, error) {
+	format := archiveFormat(filename)
+	if format == "" {
+		return nil, fmt.Errorf("%s: unknown archive format (want %s)", filename, strings.Join(archiveFormats, ", "))
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS
+	switch format {
+	case ".zip":
+		fsys, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	case ".tar.gz", ".tgz":
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			fsys, err = readTar(zr)
+		}
+	case ".tar.bz2", ".tbz2":
+		fsys, err = readTar(bzip2.NewReader(bytes.NewReader(data)))
+	default:
+		fsys, err = readTar(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return archiveRoot(fsys)
+}
+
+// readTar reads the regular files and directories of a tar stream. Links
+// and special files are skipped.
+func readTar(r io.Reader) (*treeFS, error) {
+	t := newTreeFS()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if name == "" {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			t.addDir(name)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			t.addFile(name, &treeFile{
+				mode:    hdr.FileInfo().Mode().Perm(),
+				modTime: hdr.ModTime,
+				size:    int64(len(data)),
+				read:    func() ([]byte, error) { return bytes.Clone(data), nil },
+			})
+		}
+	}
+	t.finish()
+	return t, nil
+}
+
+// archiveRoot returns the single top-level directory of an archive as its
+// root, or the archive itself if it has files at the top level or several
+// top-level directories
+func archiveRoot(fsys fs.FS) (fs.FS, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return fsys, nil
+	}
+	return fs.Sub(fsys, entries[0].Name())
+}
@@ -0,0 +1,255 @@
+package scanner
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// Rule is a single rule (or macro) invocation found in a BUILD file
+type Rule struct {
+	// Kind is the resolved rule kind, e.g. "go_test" for `go_test(...)`,
+	// `native.go_test(...)` or a load() alias of go_test
+	Kind string `json:"kind"`
+	// Symbol is the callee as written at the call site
+	Symbol string `json:"symbol"`
+	// LoadedFrom is the .bzl label the symbol was loaded from, if any
+	LoadedFrom string          `json:"loadedFrom,omitempty"`
+	Name       string          `json:"name"`
+	Attrs      map[string]Attr `json:"attrs,omitempty"`
+	// Keep is set when the rule or one of its attributes has a gazelle
+	// "# keep" comment
+	Keep bool `json:"keep,omitempty"`
+}
+
+// Attr is a simplified rule attribute value. Only literal values are kept;
+// anything else is recorded as Dynamic.
+type Attr struct {
+	// Value holds scalar values: strings, identifiers (True, False) and numbers
+	Value string `json:"value,omitempty"`
+	// Strings holds the string elements of a list, including list concatenation
+	// and every branch of a select()
+	Strings []string `json:"strings,omitempty"`
+	// Globs holds glob() calls contributing to the value
+	Globs []Glob `json:"globs,omitempty"`
+	// Dynamic is set when part of the value could not be evaluated statically
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// Glob is a glob() call in an attribute value
+type Glob struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// BuildFile is a parsed BUILD or BUILD.bazel file
+type BuildFile struct {
+	Path  string  `json:"path"`
+	Rules []*Rule `json:"rules"`
+}
+
+// AttrString returns a scalar attribute value, or "" if unset
+func (r *Rule) AttrString(key string) string {
+	return r.Attrs[key].Value
+}
+
+// AttrStrings returns the string list elements of an attribute
+func (r *Rule) AttrStrings(key string) []string {
+	return r.Attrs[key].Strings
+}
+
+// isBuildFileName reports whether filename is a Bazel BUILD file
+func isBuildFileName(filename string) bool {
+	return filename == "BUILD" || filename == "BUILD.bazel"
+}
+
+// buildFileName returns the BUILD file Bazel reads in a directory listing:
+// BUILD.bazel in preference to BUILD, or "" if there is neither
+func buildFileName(entries []os.DirEntry) string {
+	name := ""
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch entry.Name() {
+		case "BUILD.bazel":
+			return "BUILD.bazel"
+		case "BUILD":
+			name = "BUILD"
+		}
+	}
+	return name
+}
+
+// parseBuildFile parses a BUILD file into its top-level rule invocations
+func parseBuildFile(fsys fs.FS, path string) (*BuildFile, error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBuildContent(path, data)
+}
+
+func parseBuildContent(path string, data []byte) (*BuildFile, error) {
+	f, err := build.ParseBuild(filepath.Base(path), data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map local names introduced by load() to their original symbol and .bzl file
+	type loadedSymbol struct {
+		name   string
+		module string
+	}
+	loads := make(map[string]loadedSymbol)
+	for _, stmt := range f.Stmt {
+		load, ok := stmt.(*build.LoadStmt)
+		if !ok {
+			continue
+		}
+		for i := range load.To {
+			loads[load.To[i].Name] = loadedSymbol{name: load.From[i].Name, module: load.Module.Value}
+		}
+	}
+
+	bf := &BuildFile{Path: path, Rules: make([]*Rule, 0)}
+	for _, r := range f.Rules("") {
+		symbol := r.Kind()
+		if symbol == "" {
+			continue // callee is not a plain or dotted identifier
+		}
+
+		rule := &Rule{Symbol: symbol, Attrs: make(map[string]Attr)}
+		parts := strings.Split(symbol, ".")
+		switch {
+		case len(parts) == 1:
+			if ls, ok := loads[symbol]; ok {
+				rule.Kind = ls.name
+				rule.LoadedFrom = ls.module
+			} else {
+				rule.Kind = symbol
+			}
+		case parts[0] == "native":
+			rule.Kind = parts[len(parts)-1]
+		default:
+			// A rule exported through a struct, e.g. `go.test(...)`
+			rule.Kind = parts[len(parts)-1]
+			if ls, ok := loads[parts[0]]; ok {
+				rule.LoadedFrom = ls.module
+			}
+		}
+
+		for _, key := range r.AttrKeys() {
+			rule.Attrs[key] = evalAttr(r.Attr(key))
+		}
+		rule.Name = rule.AttrString("name")
+		rule.Keep = hasKeepComment(r.Call)
+
+		bf.Rules = append(bf.Rules, rule)
+	}
+
+	return bf, nil
+}
+
+// hasKeepComment reports whether any part of a rule call has a gazelle
+// "# keep" comment
+func hasKeepComment(call *build.CallExpr) bool {
+	keep := false
+	build.Walk(call, func(x build.Expr, _ []build.Expr) {
+		c := x.Comment()
+		for _, comments := range [][]build.Comment{c.Before, c.Suffix} {
+			for _, comment := range comments {
+				text := strings.TrimSpace(strings.TrimPrefix(comment.Token, "#"))
+				if text == "keep" || strings.HasPrefix(text, "keep:") {
+					keep = true
+				}
+			}
+		}
+	})
+	return keep
+}
+
+// evalAttr statically evaluates the parts of an attribute expression we care about
+func evalAttr(expr build.Expr) Attr {
+	var attr Attr
+	collectAttr(expr, &attr)
+	return attr
+}
+
+func collectAttr(expr build.Expr, attr *Attr) {
+	switch x := expr.(type) {
+	case *build.StringExpr:
+		attr.Value = x.Value
+	case *build.Ident:
+		attr.Value = x.Name
+	case *build.LiteralExpr:
+		attr.Value = x.Token
+	case *build.ListExpr:
+		for _, elem := range x.List {
+			if s, ok := elem.(*build.StringExpr); ok {
+				attr.Strings = append(attr.Strings, s.Value)
+			} else {
+				attr.Dynamic = true
+			}
+		}
+	case *build.BinaryExpr:
+		if x.Op != "+" {
+			attr.Dynamic = true
+			return
+		}
+		collectAttr(x.X, attr)
+		collectAttr(x.Y, attr)
+	case *build.CallExpr:
+		name, _ := x.X.(*build.Ident)
+		switch {
+		case name != nil && name.Name == "glob":
+			attr.Globs = append(attr.Globs, parseGlob(x))
+		case name != nil && name.Name == "select":
+			if len(x.List) == 0 {
+				attr.Dynamic = true
+				return
+			}
+			dict, ok := x.List[0].(*build.DictExpr)
+			if !ok {
+				attr.Dynamic = true
+				return
+			}
+			for _, kv := range dict.List {
+				collectAttr(kv.Value, attr)
+			}
+		default:
+			attr.Dynamic = true
+		}
+	default:
+		attr.Dynamic = true
+	}
+}
+
+func parseGlob(call *build.CallExpr) Glob {
+	var g Glob
+	for i, arg := range call.List {
+		if assign, ok := arg.(*build.AssignExpr); ok {
+			key, _ := assign.LHS.(*build.Ident)
+			if key == nil {
+				continue
+			}
+			switch key.Name {
+			case "include":
+				g.Include = build.Strings(assign.RHS)
+			case "exclude":
+				g.Exclude = build.Strings(assign.RHS)
+			}
+			continue
+		}
+		switch i {
+		case 0:
+			g.Include = build.Strings(arg)
+		case 1:
+			g.Exclude = build.Strings(arg)
+		}
+	}
+	return g
+}
@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuildFileParsing(t *testing.T) {
+	tests := []struct {
+		name  string
+		build string
+		// want lists the targets as "label kind language/role"
+		want []string
+	}{
+		{
+			name:  "plain rules",
+			build: `go_library(name = "lib", srcs = ["lib.go"])` + "\n" + `go_test(name = "lib_test", srcs = ["lib_test.go"])`,
+			want:  []string{"//pkg:lib go_library go/library", "//pkg:lib_test go_test go/test"},
+		},
+		{
+			name: "load alias",
+			build: `load("@rules_go//go:def.bzl", my_test = "go_test")
+my_test(name = "aliased", srcs = ["a_test.go"])`,
+			want: []string{"//pkg:aliased go_test go/test"},
+		},
+		{
+			name:  "native prefix",
+			build: `native.py_test(name = "native_test", srcs = ["a_test.py"])`,
+			want:  []string{"//pkg:native_test py_test python/test"},
+		},
+		{
+			name: "struct export",
+			build: `load("//tools:rust.bzl", "rust")
+rust.test(name = "struct_test", srcs = ["lib.rs"])`,
+			want: []string{"//pkg:struct_test test /"},
+		},
+		{
+			name: "rules inside strings and comments",
+			build: `DOC = """
+go_test(name = "in_string")
+"""
+# go_test(name = "in_comment")
+genrule(name = "gen", outs = ["out.txt"], cmd = "echo 'go_test(name = in_cmd)' > $@")`,
+			want: []string{"//pkg:gen genrule /"},
+		},
+		{
+			name:  "unnamed calls",
+			build: `package(default_visibility = ["//visibility:public"])` + "\n" + `exports_files(["a.txt"])`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scanFS(t, fstest.MapFS{"pkg/BUILD.bazel": file(tt.build)})
+			var got []string
+			for _, target := range result.Targets {
+				got = append(got, fmt.Sprintf("%s %s %s/%s", target.Label, target.Kind, target.Language, target.Role))
+			}
+			sort.Strings(tt.want)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("targets = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package scanner
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// ruleSetRepos maps the repository names rule sets are known by to the
+// rule set, e.g. the legacy io_bazel_rules_go to rules_go
+var ruleSetRepos = map[string]string{
+	"rules_go":          "rules_go",
+	"io_bazel_rules_go": "rules_go",
+	"rules_python":      "rules_python",
+	"rules_rust":        "rules_rust",
+}
+
+// archiveVersion finds a version in an archive name or strip_prefix, e.g.
+// "0.46.0" in "rules_go-v0.46.0.zip"
+var archiveVersion = regexp.MustCompile(`v?(\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z.]+)?)$`)
+
+// archiveExtensions are stripped from URLs before looking for a version
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar.xz", ".tar.bz2", ".tar.zst", ".zip", ".tar"}
+
+// ExternalRepo is an external repository defined in MODULE.bazel or in
+// WORKSPACE
+type ExternalRepo struct {
+	// Name is the repository's apparent name
+	Name string `json:"name"`
+	// Kind is bazel_dep, use_repo, or the repository rule that defines the
+	// repository, e.g. http_archive or go_repository
+	Kind   string `json:"kind"`
+	Bzlmod bool   `json:"bzlmod"`
+	// Module is the module name of a bazel_dep, and Extension the module
+	// extension a use_repo repository comes from, e.g.
+	// "@gazelle//:extensions.bzl%go_deps"
+	Module    string `json:"module,omitempty"`
+	Extension string `json:"extension,omitempty"`
+	Version   string `json:"version,omitempty"`
+	// Path is the directory of a local_repository, or of a bazel_dep's
+	// local_path_override, relative to the workspace unless absolute
+	Path string `json:"path,omitempty"`
+}
+
+// ExternalDeps describes how a workspace defines its external repositories
+type ExternalDeps struct {
+	// BazelVersion is the content of .bazelversion
+	BazelVersion string `json:"bazelVersion,omitempty"`
+	// ModuleFile and WorkspaceFile name the files found, e.g. "MODULE.bazel"
+	// and "WORKSPACE.bazel"
+	ModuleFile    string `json:"moduleFile,omitempty"`
+	WorkspaceFile string `json:"workspaceFile,omitempty"`
+	// ModuleName and ModuleVersion come from the module() call
+	ModuleName    string          `json:"moduleName,omitempty"`
+	ModuleVersion string          `json:"moduleVersion,omitempty"`
+	Repos         []*ExternalRepo `json:"repos"`
+	// RulesVersions are the versions of rules_go, rules_python and
+	// rules_rust, preferring MODULE.bazel over WORKSPACE
+	RulesVersions map[string]string `json:"rulesVersions"`
+}
+
+// loadExternalDeps reads MODULE.bazel, WORKSPACE.bazel or WORKSPACE, and
+// .bazelversion in a workspace root. It returns nil if there are none.
+func loadExternalDeps(fsys fs.FS, dir string) *ExternalDeps {
+	deps := &ExternalDeps{
+		Repos:         make([]*ExternalRepo, 0),
+		RulesVersions: make(map[string]string),
+	}
+	found := false
+
+	if data, err := fs.ReadFile(fsys, path.Join(dir, ".bazelversion")); err == nil {
+		deps.BazelVersion = strings.TrimSpace(string(data))
+		found = true
+	}
+	if data, err := fs.ReadFile(fsys, path.Join(dir, "MODULE.bazel")); err == nil {
+		deps.ModuleFile = "MODULE.bazel"
+		found = true
+		if f, err := build.ParseModule("MODULE.bazel", data); err == nil {
+			deps.addModule(f)
+		}
+	}
+	for _, name := range []string{"WORKSPACE.bazel", "WORKSPACE"} {
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		deps.WorkspaceFile = name
+		found = true
+		if f, err := build.ParseWorkspace(name, data); err == nil {
+			deps.addWorkspace(f)
+		}
+		break
+	}
+	if !found {
+		return nil
+	}
+
+	for _, repo := range deps.Repos {
+		ruleSet := ruleSetRepos[repo.Name]
+		if repo.Module != "" {
+			ruleSet = ruleSetRepos[repo.Module]
+		}
+		if ruleSet == "" || repo.Version == "" {
+			continue
+		}
+		if _, ok := deps.RulesVersions[ruleSet]; !ok || repo.Bzlmod {
+			deps.RulesVersions[ruleSet] = repo.Version
+		}
+	}
+	sort.SliceStable(deps.Repos, func(i, j int) bool {
+		return deps.Repos[i].Name < deps.Repos[j].Name
+	})
+	return deps
+}
+
+// addModule records the module, its bazel_deps and the repositories it
+// imports from module extensions or defines with use_repo_rule
+func (d *ExternalDeps) addModule(f *build.File) {
+	extensions := make(map[string]string) // variable -> extension
+	repoRules := make(map[string]string)  // variable -> repository rule
+	overrides := make(map[string]string)  // module -> local_path_override path
+	for _, stmt := range f.Stmt {
+		if assign, ok := stmt.(*build.AssignExpr); ok {
+			lhs, _ := assign.LHS.(*build.Ident)
+			call, _ := assign.RHS.(*build.CallExpr)
+			if lhs == nil || call == nil {
+				continue
+			}
+			args := positionalStrings(call)
+			switch callName(call) {
+			case "use_extension":
+				if len(args) == 2 {
+					extensions[lhs.Name] = args[0] + "%" + args[1]
+				}
+			case "use_repo_rule":
+				if len(args) == 2 {
+					repoRules[lhs.Name] = args[1]
+				}
+			}
+			continue
+		}
+
+		call, ok := stmt.(*build.CallExpr)
+		if !ok {
+			continue
+		}
+		switch name := callName(call); name {
+		case "module":
+			d.ModuleName = keywordString(call, "name")
+			d.ModuleVersion = keywordString(call, "version")
+		case "bazel_dep":
+			module := keywordString(call, "name")
+			repo := &ExternalRepo{
+				Name:    keywordString(call, "repo_name"),
+				Kind:    name,
+				Bzlmod:  true,
+				Module:  module,
+				Version: keywordString(call, "version"),
+			}
+			if repo.Name == "" {
+				repo.Name = module
+			}
+			d.Repos = append(d.Repos, repo)
+		case "use_repo":
+			if len(call.List) == 0 {
+				continue
+			}
+			ext := ""
+			if ident, ok := call.List[0].(*build.Ident); ok {
+				ext = extensions[ident.Name]
+			}
+			for _, arg := range call.List[1:] {
+				repo := &ExternalRepo{Kind: name, Bzlmod: true, Extension: ext}
+				switch x := arg.(type) {
+				case *build.StringExpr:
+					repo.Name = x.Value
+				case *build.AssignExpr:
+					if key, ok := x.LHS.(*build.Ident); ok {
+						repo.Name = key.Name
+					}
+				}
+				if repo.Name != "" {
+					d.Repos = append(d.Repos, repo)
+				}
+			}
+		case "local_path_override":
+			overrides[keywordString(call, "module_name")] = keywordString(call, "path")
+		default:
+			if rule, ok := repoRules[name]; ok {
+				if repo := repositoryRule(call, rule); repo != nil {
+					repo.Bzlmod = true
+					d.Repos = append(d.Repos, repo)
+				}
+			}
+		}
+	}
+	for _, repo := range d.Repos {
+		if repo.Kind == "bazel_dep" {
+			if dir, ok := overrides[repo.Module]; ok {
+				repo.Path = dir
+			}
+		}
+	}
+}
+
+// addWorkspace records the repository rules called in a WORKSPACE file,
+// including those wrapped in maybe(). Repositories defined by macros, like
+// go_rules_dependencies(), are not visible without evaluating them, and
+// bind() only aliases targets of other repositories.
+func (d *ExternalDeps) addWorkspace(f *build.File) {
+	for _, stmt := range f.Stmt {
+		call, ok := stmt.(*build.CallExpr)
+		if !ok {
+			continue
+		}
+		kind := callName(call)
+		switch kind {
+		case "workspace", "bind":
+			continue
+		case "maybe":
+			// maybe(http_archive, name = ...) calls the rule it is given
+			if len(call.List) == 0 {
+				continue
+			}
+			switch rule := call.List[0].(type) {
+			case *build.Ident:
+				kind = rule.Name
+			case *build.DotExpr:
+				kind = rule.Name
+			default:
+				continue
+			}
+		}
+		if repo := repositoryRule(call, kind); repo != nil {
+			d.Repos = append(d.Repos, repo)
+		}
+	}
+}
+
+// repositoryRule returns the repository a repository rule call defines, or
+// nil if the call has no name
+func repositoryRule(call *build.CallExpr, kind string) *ExternalRepo {
+	name := keywordString(call, "name")
+	if name == "" {
+		return nil
+	}
+	repo := &ExternalRepo{Name: name, Kind: kind, Path: keywordString(call, "path")}
+	switch {
+	case keywordString(call, "version") != "":
+		repo.Version = strings.TrimPrefix(keywordString(call, "version"), "v")
+	case keywordString(call, "tag") != "":
+		repo.Version = strings.TrimPrefix(keywordString(call, "tag"), "v")
+	default:
+		candidates := []string{keywordString(call, "strip_prefix"), keywordString(call, "url")}
+		for _, attr := range call.List {
+			if assign, ok := attr.(*build.AssignExpr); ok {
+				if key, ok := assign.LHS.(*build.Ident); ok && key.Name == "urls" {
+					candidates = append(candidates, build.Strings(assign.RHS)...)
+				}
+			}
+		}
+		for _, c := range candidates {
+			if v := versionFromArchive(c); v != "" {
+				repo.Version = v
+				break
+			}
+		}
+	}
+	return repo
+}
+
+// versionFromArchive extracts a version from an archive URL or strip_prefix
+func versionFromArchive(s string) string {
+	base := path.Base(s)
+	for _, ext := range archiveExtensions {
+		base = strings.TrimSuffix(base, ext)
+	}
+	if m := archiveVersion.FindStringSubmatch(base); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// callName returns the name of a called function, the last part of a
+// dotted name
+func callName(call *build.CallExpr) string {
+	switch fn := call.X.(type) {
+	case *build.Ident:
+		return fn.Name
+	case *build.DotExpr:
+		return fn.Name
+	}
+	return ""
+}
+
+// keywordString returns a string keyword argument of a call, or ""
+func keywordString(call *build.CallExpr, key string) string {
+	for _, arg := range call.List {
+		assign, ok := arg.(*build.AssignExpr)
+		if !ok {
+			continue
+		}
+		if k, ok := assign.LHS.(*build.Ident); ok && k.Name == key {
+			if s, ok := assign.RHS.(*build.StringExpr); ok {
+				return s.Value
+			}
+		}
+	}
+	return ""
+}
+
+// positionalStrings returns the leading string positional arguments of a call
+func positionalStrings(call *build.CallExpr) []string {
+	var args []string
+	for _, arg := range call.List {
+		s, ok := arg.(*build.StringExpr)
+		if !ok {
+			break
+		}
+		args = append(args, s.Value)
+	}
+	return args
+}
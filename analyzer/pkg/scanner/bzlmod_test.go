@@ -0,0 +1,125 @@
+package scanner
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// repoSummary formats a repository as "name kind module extension version
+// path" for comparisons
+func repoSummary(r *ExternalRepo) string {
+	return fmt.Sprintf("%s %s %s %s %s %s", r.Name, r.Kind, r.Module, r.Extension, r.Version, r.Path)
+}
+
+func TestAddModule(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "bazel_dep",
+			content: `bazel_dep(name = "rules_go", version = "0.46.0")`,
+			want:    []string{"rules_go bazel_dep rules_go  0.46.0 "},
+		},
+		{
+			name:    "repo_name",
+			content: `bazel_dep(name = "rules_go", version = "0.46.0", repo_name = "io_bazel_rules_go")`,
+			want:    []string{"io_bazel_rules_go bazel_dep rules_go  0.46.0 "},
+		},
+		{
+			name: "local_path_override",
+			content: `bazel_dep(name = "lib", version = "1.0")
+local_path_override(module_name = "lib", path = "third_party/lib")`,
+			want: []string{"lib bazel_dep lib  1.0 third_party/lib"},
+		},
+		{
+			name: "use_repo with keyword args",
+			content: `go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+use_repo(go_deps, "com_github_foo", bar = "com_github_bar")`,
+			want: []string{
+				"com_github_foo use_repo  @gazelle//:extensions.bzl%go_deps  ",
+				"bar use_repo  @gazelle//:extensions.bzl%go_deps  ",
+			},
+		},
+		{
+			name: "use_repo_rule",
+			content: `http_archive = use_repo_rule("@bazel_tools//tools/build_defs/repo:http.bzl", "http_archive")
+http_archive(name = "zlib", urls = ["https://zlib.net/zlib-1.3.1.tar.gz"])`,
+			want: []string{"zlib http_archive   1.3.1 "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := build.ParseModule("MODULE.bazel", []byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := &ExternalDeps{}
+			d.addModule(f)
+			var got []string
+			for _, repo := range d.Repos {
+				if !repo.Bzlmod {
+					t.Errorf("%s is not marked bzlmod", repo.Name)
+				}
+				got = append(got, repoSummary(repo))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("repos = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddWorkspace(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "http_archive",
+			content: `http_archive(
+    name = "io_bazel_rules_go",
+    urls = ["https://github.com/bazelbuild/rules_go/releases/download/v0.46.0/rules_go-v0.46.0.zip"],
+)`,
+			want: []string{"io_bazel_rules_go http_archive   0.46.0 "},
+		},
+		{
+			name:    "local_repository",
+			content: `local_repository(name = "sub", path = "sub")`,
+			want:    []string{"sub local_repository    sub"},
+		},
+		{
+			name: "maybe",
+			content: `load("@bazel_tools//tools/build_defs/repo:utils.bzl", "maybe")
+maybe(http_archive, name = "zlib", strip_prefix = "zlib-1.3.1")
+maybe(repo.git_repository, name = "foo", tag = "v2.0")`,
+			want: []string{"zlib http_archive   1.3.1 ", "foo git_repository   2.0 "},
+		},
+		{
+			name: "bind and workspace",
+			content: `workspace(name = "main")
+bind(name = "python_headers", actual = "@python//:headers")`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := build.ParseWorkspace("WORKSPACE", []byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := &ExternalDeps{}
+			d.addWorkspace(f)
+			var got []string
+			for _, repo := range d.Repos {
+				got = append(got, repoSummary(repo))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("repos = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheVersion is bumped whenever the cached directory format or the way
+// directories are classified changes, invalidating existing caches
+const cacheVersion = 18
+
+// CacheStats reports how many directories were reused from the scan cache
+type CacheStats struct {
+	Enabled bool `json:"enabled"`
+	Hits    int  `json:"hits"`
+	Misses  int  `json:"misses"`
+}
+
+// cacheFile is the on-disk format of the scan cache
+type cacheFile struct {
+	// Settings fingerprints everything outside a directory that affects its
+	// result; a mismatch discards the whole cache
+	Settings string                `json:"settings"`
+	Dirs     map[string]*cachedDir `json:"dirs"`
+}
+
+// cachedDir is the scan result of a single directory
+type cachedDir struct {
+	Fingerprint   string                       `json:"fingerprint"`
+	HasBuild      bool                         `json:"hasBuild,omitempty"`
+	BuildFiles    int                          `json:"buildFiles,omitempty"`
+	Targets       map[string]int               `json:"targets,omitempty"`
+	Packages      []Package                    `json:"packages,omitempty"`
+	ExcludedLangs map[Language]ExclusionReason `json:"excludedLangs,omitempty"`
+	Proto         *protoDir                    `json:"proto,omitempty"`
+	TargetList    []*Target                    `json:"targetList,omitempty"`
+	Files         map[Language]*dirFiles       `json:"files,omitempty"`
+}
+
+// scanCache holds the cache loaded from disk and the entries of the current
+// scan, which replace it when saved
+type scanCache struct {
+	path     string
+	settings string
+	prev     map[string]*cachedDir
+
+	mu   sync.Mutex
+	next map[string]*cachedDir
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// openCache loads the cache at path. A missing, unreadable or outdated cache
+// starts empty, as does any cache when fullRescan is set.
+func openCache(path, settings string, fullRescan bool) *scanCache {
+	c := &scanCache{
+		path:     path,
+		settings: settings,
+		prev:     make(map[string]*cachedDir),
+		next:     make(map[string]*cachedDir),
+	}
+	if fullRescan {
+		return c
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var f cacheFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Settings != settings || f.Dirs == nil {
+		return c
+	}
+	c.prev = f.Dirs
+	return c
+}
+
+// lookup returns the cached result for a directory if its fingerprint matches
+func (c *scanCache) lookup(relPath, fingerprint string) *cachedDir {
+	if cd, ok := c.prev[relPath]; ok && cd.Fingerprint == fingerprint {
+		c.hits.Add(1)
+		return cd
+	}
+	c.misses.Add(1)
+	return nil
+}
+
+// store records a directory's result for the next scan
+func (c *scanCache) store(relPath string, cd *cachedDir) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next[relPath] = cd
+}
+
+// save atomically replaces the on-disk cache with the current scan's entries
+func (c *scanCache) save() error {
+	data, err := json.Marshal(cacheFile{Settings: c.settings, Dirs: c.next})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *scanCache) stats() *CacheStats {
+	return &CacheStats{
+		Enabled: true,
+		Hits:    int(c.hits.Load()),
+		Misses:  int(c.misses.Load()),
+	}
+}
+
+// DefaultCachePath returns the cache file used for a repository when no
+// explicit path is given, under the user's cache directory. Scans whose
+// directory fingerprints are not comparable, like those of the working tree
+// and of git trees, would replace each other's entries in a shared cache, so
+// a non-empty mode such as "git" selects a cache file of its own.
+func DefaultCachePath(repoPath, mode string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(repoPath))
+	name := hex.EncodeToString(sum[:8])
+	if mode != "" {
+		name += "-" + mode
+	}
+	return filepath.Join(dir, "bazel-metrics", name+".json"), nil
+}
+
+// cacheSettings fingerprints the scanner settings that affect every directory
+func (s *Scanner) cacheSettings(bazelIgnores []string) string {
+	h := sha256.New()
+	cfg, _ := json.Marshal(s.config)
+	fmt.Fprintf(h, "version=%d\nconfig=%s\ngo=%s\nbazelignore=%s\n", cacheVersion, cfg, s.goBackend, strings.Join(bazelIgnores, ","))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// dirFingerprint identifies the inputs of a directory's scan result: the
+// state inherited from its parents, its listing with file sizes and mtimes
+// or git blob ids, and the content of its BUILD files
+func (s *Scanner) dirFingerprint(dp *dirPackages, ignore *gitignore, entries []os.DirEntry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "excluded=%s\nignore=%s\ngazelle=%s\nworkspace=%s\n", dp.excluded, ignore.key(), dp.gazelle.key(), dp.workspace.key())
+	for _, d := range s.languages {
+		fmt.Fprintf(h, "%s=%s\n", d.Language(), dp.states[d.Language()].Key())
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+			fmt.Fprintf(h, "d %s\n", name)
+		case isBuildFileName(name):
+			data, err := fs.ReadFile(s.fsys, dp.file(name))
+			if err != nil {
+				fmt.Fprintf(h, "? %s\n", name)
+				continue
+			}
+			fmt.Fprintf(h, "b %s %x\n", name, sha256.Sum256(data))
+		default:
+			info, err := entry.Info()
+			if err != nil {
+				fmt.Fprintf(h, "? %s\n", name)
+				continue
+			}
+			if oid, ok := info.Sys().(gitObjectID); ok {
+				fmt.Fprintf(h, "g %s %s %v\n", name, oid, info.Mode().Type())
+				continue
+			}
+			fmt.Fprintf(h, "f %s %d %d %v\n", name, info.Size(), info.ModTime().UnixNano(), info.Mode().Type())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// toCache captures a freshly scanned directory for the cache. The go list
+// and gazelle passes later drop files and add exclusions, so the entry gets
+// its own copies of those maps.
+func (dp *dirPackages) toCache(fingerprint string) *cachedDir {
+	cd := &cachedDir{
+		Fingerprint:   fingerprint,
+		HasBuild:      dp.hasBuild,
+		BuildFiles:    dp.buildFiles,
+		ExcludedLangs: maps.Clone(dp.excludedLangs),
+		Proto:         dp.proto,
+		TargetList:    dp.targetList,
+		Files:         cloneFiles(dp.files),
+	}
+	if len(dp.targets) > 0 {
+		cd.Targets = make(map[string]int, len(dp.targets))
+		for class, n := range dp.targets {
+			cd.Targets[string(class.lang)+"/"+string(class.role)] = n
+		}
+	}
+	for _, pkg := range dp.pkgs {
+		cd.Packages = append(cd.Packages, *pkg)
+	}
+	return cd
+}
+
+// cloneFiles copies a directory's classified files
+func cloneFiles(files map[Language]*dirFiles) map[Language]*dirFiles {
+	if files == nil {
+		return nil
+	}
+	clone := make(map[Language]*dirFiles, len(files))
+	for lang, f := range files {
+		clone[lang] = &dirFiles{Sources: slices.Clone(f.Sources), Tests: slices.Clone(f.Tests), Generated: slices.Clone(f.Generated)}
+	}
+	return clone
+}
+
+// fromCache restores a directory's scan result from the cache, copying the
+// maps later passes modify since the entry is saved again
+func (dp *dirPackages) fromCache(cd *cachedDir) {
+	dp.hasBuild = cd.HasBuild
+	dp.buildFiles = cd.BuildFiles
+	dp.excludedLangs = maps.Clone(cd.ExcludedLangs)
+	dp.proto = cd.Proto
+	dp.targetList = cd.TargetList
+	dp.files = cloneFiles(cd.Files)
+	if dp.hasBuild {
+		dp.targets = make(buildTargets, len(cd.Targets))
+		for key, n := range cd.Targets {
+			lang, role, _ := strings.Cut(key, "/")
+			dp.targets[ruleClass{lang: Language(lang), role: RuleRole(role)}] = n
+		}
+	}
+	if len(cd.Packages) > 0 {
+		dp.pkgs = make(map[Language]*Package, len(cd.Packages))
+	}
+	for i := range cd.Packages {
+		pkg := cd.Packages[i]
+		pkg.Path = dp.path
+		dp.pkgs[pkg.Language] = &pkg
+	}
+}
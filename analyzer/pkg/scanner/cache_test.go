@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCachedDirIsNotModifiedByLaterPasses(t *testing.T) {
+	dp := &dirPackages{relPath: "pkg"}
+	dp.addDirFile(LangGo, FileSource, "a.go")
+	dp.addDirFile(LangPython, FileTest, "a_test.py")
+	dp.exclude(LangRust, ExcludedByGitignore)
+
+	cd := dp.toCache("fp")
+	want := &cachedDir{
+		Fingerprint:   "fp",
+		ExcludedLangs: map[Language]ExclusionReason{LangRust: ExcludedByGitignore},
+		Files: map[Language]*dirFiles{
+			LangGo:     {Sources: []string{"a.go"}},
+			LangPython: {Tests: []string{"a_test.py"}},
+		},
+	}
+
+	// What applyGoList does to a module's directories
+	delete(dp.files, LangGo)
+	dp.exclude(LangGo, ExcludedGoList)
+	dp.files[LangPython].Tests[0] = "b_test.py"
+	if !reflect.DeepEqual(cd, want) {
+		t.Errorf("toCache entry changed with the directory: got %+v, want %+v", cd, want)
+	}
+
+	restored := &dirPackages{relPath: "pkg"}
+	restored.fromCache(cd)
+	delete(restored.files, LangPython)
+	restored.exclude(LangPython, ExcludedByGazelleIgnore)
+	if !reflect.DeepEqual(cd, want) {
+		t.Errorf("cached entry changed with the restored directory: got %+v, want %+v", cd, want)
+	}
+}
+
+func TestCacheSettingsIncludeGoBackend(t *testing.T) {
+	walk := NewScanner("/repo", WithGoBackend(GoBackendWalk)).cacheSettings(nil)
+	golist := NewScanner("/repo", WithGoBackend(GoBackendGoList)).cacheSettings(nil)
+	if walk == golist {
+		t.Error("switching the Go backend keeps the cache settings")
+	}
+}
+
+func TestDefaultCachePathPerMode(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	workingTree, err := DefaultCachePath("/repo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	git, err := DefaultCachePath("/repo", "git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workingTree == git {
+		t.Errorf("git scans share the working tree's cache file %s", git)
+	}
+}
+
+// scanResultJSON scans and marshals the result without its cache stats,
+// which are the only part of a result expected to differ between cold and
+// warm scans
+func scanResultJSON(t *testing.T, fsys fs.FS, opts ...Option) []byte {
+	t.Helper()
+	result := scanFS(t, fsys, opts...)
+	result.Cache = nil
+	return marshal(t, result)
+}
+
+func TestScanCacheEquivalence(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"merged crate", rustModuleFS(20)},
+		{"mixed languages", mixedFS()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cachePath := filepath.Join(t.TempDir(), "cache.json")
+			want := scanResultJSON(t, tt.fsys)
+
+			if got := scanResultJSON(t, tt.fsys, WithCache(cachePath, false)); !bytes.Equal(got, want) {
+				t.Error("cold cached scan differs from the uncached scan")
+			}
+			warm := scanFS(t, tt.fsys, WithCache(cachePath, false))
+			if warm.Cache.Misses != 0 {
+				t.Errorf("warm scan missed the cache for %d directories", warm.Cache.Misses)
+			}
+			warm.Cache = nil
+			if got := marshal(t, warm); !bytes.Equal(got, want) {
+				t.Error("warm cached scan differs from the uncached scan")
+			}
+		})
+	}
+}
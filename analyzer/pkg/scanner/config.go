@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RuleRole is the part a rule kind plays in a package
+type RuleRole string
+
+const (
+	RoleTest    RuleRole = "test"
+	RoleLibrary RuleRole = "library"
+	RoleBinary  RuleRole = "binary"
+)
+
+// RuleMapping maps a rule or macro name to a language and role
+type RuleMapping struct {
+	// Kind is the rule or macro name as exported by its .bzl file
+	Kind string `json:"kind"`
+	// Load optionally restricts the mapping to symbols loaded from this .bzl
+	// label, e.g. "//tools/go:defs.bzl"
+	Load     string   `json:"load,omitempty"`
+	Language Language `json:"language"`
+	Role     RuleRole `json:"role"`
+}
+
+// Config is the analyzer configuration file
+type Config struct {
+	// Rules maps custom rules and macros on top of the built-in rule kinds
+	Rules []RuleMapping `json:"rules"`
+
+	// GoBuildTags are build tags treated as set when evaluating Go build
+	// constraints, e.g. "integration"
+	GoBuildTags []string `json:"goBuildTags,omitempty"`
+
+	// GeneratedFiles are name patterns of generated files on top of the
+	// built-in ones, e.g. "*_mock.go". Patterns with a slash match the
+	// repo-relative path, e.g. "api/gen/*.py".
+	GeneratedFiles []string `json:"generatedFiles,omitempty"`
+}
+
+// LoadConfig reads a JSON config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for i, m := range cfg.Rules {
+		if m.Kind == "" {
+			return nil, fmt.Errorf("%s: rules[%d]: kind is required", path, i)
+		}
+		switch m.Role {
+		case RoleTest, RoleLibrary, RoleBinary:
+		default:
+			return nil, fmt.Errorf("%s: rules[%d] (%s): unknown role %q", path, i, m.Kind, m.Role)
+		}
+		// Languages are checked against the registered detectors at scan time
+		if m.Language == "" {
+			return nil, fmt.Errorf("%s: rules[%d] (%s): language is required", path, i, m.Kind)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// ruleClass is the language and role a rule kind is accounted under
+type ruleClass struct {
+	lang Language
+	role RuleRole
+}
+
+// ruleClassifier resolves rule kinds to a language and role
+type ruleClassifier struct {
+	byKind map[string]ruleClass
+	// byLoad holds mappings restricted to a .bzl file, keyed by label then kind
+	byLoad map[string]map[string]ruleClass
+}
+
+// newRuleClassifier recognizes the languages' built-in rule kinds plus the
+// config's mappings
+func newRuleClassifier(languages []LanguageDetector, cfg *Config) *ruleClassifier {
+	c := &ruleClassifier{
+		byKind: make(map[string]ruleClass),
+		byLoad: make(map[string]map[string]ruleClass),
+	}
+	for _, d := range languages {
+		for role, kinds := range d.RuleKinds() {
+			for _, kind := range kinds {
+				c.byKind[kind] = ruleClass{lang: d.Language(), role: role}
+			}
+		}
+	}
+	if cfg == nil {
+		return c
+	}
+
+	for _, m := range cfg.Rules {
+		class := ruleClass{lang: m.Language, role: m.Role}
+		if m.Load == "" {
+			c.byKind[m.Kind] = class
+			continue
+		}
+		label := normalizeLoadLabel(m.Load)
+		if c.byLoad[label] == nil {
+			c.byLoad[label] = make(map[string]ruleClass)
+		}
+		c.byLoad[label][m.Kind] = class
+	}
+	return c
+}
+
+// classify returns the class of a rule; load-specific mappings win over
+// mappings by kind alone
+func (c *ruleClassifier) classify(rule *Rule) (ruleClass, bool) {
+	if rule.LoadedFrom != "" {
+		if class, ok := c.byLoad[normalizeLoadLabel(rule.LoadedFrom)][rule.Kind]; ok {
+			return class, true
+		}
+	}
+	class, ok := c.byKind[rule.Kind]
+	return class, ok
+}
+
+// normalizeLoadLabel makes main-repository labels comparable, so that
+// "@//tools:defs.bzl" and "//tools:defs.bzl" match
+func normalizeLoadLabel(label string) string {
+	label = strings.TrimPrefix(label, "@@//")
+	label = strings.TrimPrefix(label, "@//")
+	label = strings.TrimPrefix(label, "//")
+	return label
+}
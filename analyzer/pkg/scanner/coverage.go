@@ -0,0 +1,208 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// dirFiles are a directory's files of one language, by kind. A Rust module
+// with inline tests is in both lists.
+type dirFiles struct {
+	Sources []string `json:"sources,omitempty"`
+	Tests   []string `json:"tests,omitempty"`
+	// Generated are the generated sources, which count towards neither
+	Generated []string `json:"generated,omitempty"`
+}
+
+// addDirFile records a classified file for file-level coverage
+func (dp *dirPackages) addDirFile(lang Language, kind FileKind, filename string) {
+	if kind == FileSupport {
+		return
+	}
+	files := dp.dirFiles(lang)
+	if kind == FileSource || kind == FileSourceWithTests {
+		files.Sources = append(files.Sources, filename)
+	}
+	if kind == FileTest || kind == FileSourceWithTests {
+		files.Tests = append(files.Tests, filename)
+	}
+}
+
+// addGeneratedFile records a generated source, which only matters for
+// whether an ancestor's targets list the directory's files
+func (dp *dirPackages) addGeneratedFile(lang Language, filename string) {
+	files := dp.dirFiles(lang)
+	files.Generated = append(files.Generated, filename)
+}
+
+// dirFiles returns the directory's files of a language, adding them if
+// there are none yet
+func (dp *dirPackages) dirFiles(lang Language) *dirFiles {
+	if dp.files == nil {
+		dp.files = make(map[Language]*dirFiles)
+	}
+	files := dp.files[lang]
+	if files == nil {
+		files = &dirFiles{}
+		dp.files[lang] = files
+	}
+	return files
+}
+
+// applyFileCoverage resolves the srcs of each directory's Bazel package, the
+// nearest enclosing directory with a BUILD file, against the directory's
+// files. It records the test files no test target lists and the source files
+// no target of their language lists.
+func applyFileCoverage(dirs []*dirPackages) {
+	byRel := indexDirs(dirs)
+	for _, dp := range dirs {
+		if len(dp.pkgs) == 0 {
+			continue
+		}
+		owner, prefix := owningPackage(byRel, filepath.ToSlash(dp.relPath))
+		for lang, pkg := range dp.pkgs {
+			files := dp.files[lang]
+			if files == nil {
+				continue
+			}
+			var tests, all []*Target
+			if owner != nil {
+				tests, all = coveringTargets(owner.targetList, lang)
+			}
+			pkg.UncoveredTestFiles = uncoveredFiles(files.Tests, prefix, owner, tests)
+			pkg.UncoveredSourceFiles = uncoveredFiles(files.Sources, prefix, owner, all)
+			pkg.listedGeneratedFiles = len(files.Generated) - len(uncoveredFiles(files.Generated, prefix, owner, all))
+		}
+	}
+}
+
+// coveringTargets returns a package's test targets of a language and all of
+// its targets of the language. A rust_test with a crate attribute also
+// covers the srcs of that crate.
+func coveringTargets(targets []*Target, lang Language) (tests, all []*Target) {
+	byName := make(map[string]*Target, len(targets))
+	for _, t := range targets {
+		byName[t.Name] = t
+	}
+	for _, t := range targets {
+		if t.Language != lang {
+			continue
+		}
+		all = append(all, t)
+		if t.Role != RoleTest {
+			continue
+		}
+		tests = append(tests, t)
+		if crate := byName[strings.TrimPrefix(t.Crate, ":")]; crate != nil && t.Crate != "" {
+			tests = append(tests, crate)
+		}
+	}
+	return tests, all
+}
+
+// uncoveredFiles returns the files none of the owner's targets lists.
+// prefix is the files' directory relative to the owning package.
+func uncoveredFiles(files []string, prefix string, owner *dirPackages, targets []*Target) []string {
+	var uncovered []string
+	for _, name := range files {
+		file := name
+		if prefix != "" {
+			file = prefix + "/" + name
+		}
+		covered := false
+		for _, t := range targets {
+			if t.lists(owner.pkgPath(), file) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			uncovered = append(uncovered, name)
+		}
+	}
+	return uncovered
+}
+
+// lists reports whether the target's srcs or hdrs name a file, given as a
+// path relative to the target's package. Targets whose srcs could not be
+// evaluated statically are assumed to list every file.
+func (t *Target) lists(pkgSlash, file string) bool {
+	if t.SrcsDynamic {
+		return true
+	}
+	for _, labels := range [][]string{t.Srcs, t.Hdrs} {
+		for _, label := range labels {
+			if name, ok := localFileLabel(pkgSlash, label); ok && name == file {
+				return true
+			}
+		}
+	}
+	for _, globs := range [][]Glob{t.SrcGlobs, t.HdrGlobs} {
+		for _, g := range globs {
+			if g.matches(file) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listsData reports whether the target's data names a file, given as a path
+// relative to the target's package
+func (t *Target) listsData(pkgSlash, file string) bool {
+	if t.DataDynamic {
+		return true
+	}
+	for _, label := range t.Data {
+		if name, ok := localFileLabel(pkgSlash, label); ok && name == file {
+			return true
+		}
+	}
+	for _, g := range t.DataGlobs {
+		if g.matches(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// localFileLabel returns the package-relative name of a srcs entry that
+// refers to a file of the package itself: "a.go", ":a.go" or "//pkg:a.go"
+func localFileLabel(pkgSlash, label string) (string, bool) {
+	switch {
+	case strings.HasPrefix(label, "@"):
+		return "", false
+	case strings.HasPrefix(label, "//"):
+		pkg, name, ok := strings.Cut(label[2:], ":")
+		if pkgSlash == "." {
+			pkgSlash = ""
+		}
+		if !ok || pkg != pkgSlash {
+			return "", false
+		}
+		return name, true
+	}
+	return strings.TrimPrefix(label, ":"), true
+}
+
+// matches reports whether a package-relative path matches the glob's
+// include patterns and none of its exclude patterns
+func (g Glob) matches(file string) bool {
+	for _, pattern := range g.Exclude {
+		if globMatch(pattern, file) {
+			return false
+		}
+	}
+	for _, pattern := range g.Include {
+		if globMatch(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches a slash-separated path against a Bazel glob pattern,
+// where "**" matches any number of directories
+func globMatch(pattern, file string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
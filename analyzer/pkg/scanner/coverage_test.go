@@ -0,0 +1,85 @@
+package scanner
+
+import "testing"
+
+func TestLocalFileLabel(t *testing.T) {
+	tests := []struct {
+		pkg, label string
+		want       string
+		wantOK     bool
+	}{
+		{".", "a.go", "a.go", true},
+		{".", ":a.go", "a.go", true},
+		{".", "//:a.go", "a.go", true},
+		{".", "//pkg:a.go", "", false},
+		{"pkg", "a.go", "a.go", true},
+		{"pkg", ":a.go", "a.go", true},
+		{"pkg", "//pkg:a.go", "a.go", true},
+		{"pkg", "//pkg:sub/a.go", "sub/a.go", true},
+		{"pkg", "//other:a.go", "", false},
+		{"pkg", "//pkg/sub:a.go", "", false},
+		{"pkg/sub", "//pkg/sub:a.go", "a.go", true},
+		{"pkg", "//pkg", "", false},
+		{"pkg", "@repo//pkg:a.go", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := localFileLabel(tt.pkg, tt.label)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("localFileLabel(%q, %q) = %q, %v; want %q, %v", tt.pkg, tt.label, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestTargetLists(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		pkg    string
+		file   string
+		want   bool
+	}{
+		{"plain src", Target{Srcs: []string{"a.go"}}, "pkg", "a.go", true},
+		{"other src", Target{Srcs: []string{"b.go"}}, "pkg", "a.go", false},
+		{"colon src in the root package", Target{Srcs: []string{":a.go"}}, ".", "a.go", true},
+		{"absolute src in the root package", Target{Srcs: []string{"//:a.go"}}, ".", "a.go", true},
+		{"absolute src in a nested package", Target{Srcs: []string{"//pkg/sub:a.go"}}, "pkg/sub", "a.go", true},
+		{"absolute src of another package", Target{Srcs: []string{"//pkg:a.go"}}, "pkg/sub", "a.go", false},
+		{"src in a subdirectory", Target{Srcs: []string{"sub/a.go"}}, "pkg", "sub/a.go", true},
+		{"hdr", Target{Hdrs: []string{"a.h"}}, "pkg", "a.h", true},
+		{"glob", Target{SrcGlobs: []Glob{{Include: []string{"*.go"}}}}, "pkg", "a.go", true},
+		{"glob does not cross directories", Target{SrcGlobs: []Glob{{Include: []string{"*.go"}}}}, "pkg", "sub/a.go", false},
+		{"glob exclude", Target{SrcGlobs: []Glob{{Include: []string{"*.go"}, Exclude: []string{"*_test.go"}}}}, "pkg", "a_test.go", false},
+		{"glob exclude leaves other files", Target{SrcGlobs: []Glob{{Include: []string{"*.go"}, Exclude: []string{"*_test.go"}}}}, "pkg", "a.go", true},
+		{"** across directories", Target{SrcGlobs: []Glob{{Include: []string{"**/*.py"}}}}, "pkg", "a/b/c.py", true},
+		{"** matches no directory", Target{SrcGlobs: []Glob{{Include: []string{"**/*.py"}}}}, "pkg", "c.py", true},
+		{"inner **", Target{SrcGlobs: []Glob{{Include: []string{"src/**/*.rs"}}}}, "pkg", "src/a/lib.rs", true},
+		{"trailing **", Target{SrcGlobs: []Glob{{Include: []string{"data/**"}}}}, "pkg", "data/x/y.json", true},
+		{"** exclude", Target{SrcGlobs: []Glob{{Include: []string{"**/*.py"}, Exclude: []string{"tests/**"}}}}, "pkg", "tests/a/test_x.py", false},
+		{"hdr glob", Target{HdrGlobs: []Glob{{Include: []string{"*.h"}}}}, "pkg", "a.h", true},
+		{"dynamic srcs", Target{SrcsDynamic: true}, "pkg", "anything.go", true},
+	}
+	for _, tt := range tests {
+		if got := tt.target.lists(tt.pkg, tt.file); got != tt.want {
+			t.Errorf("%s: lists(%q, %q) = %v, want %v", tt.name, tt.pkg, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestTargetListsData(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		file   string
+		want   bool
+	}{
+		{"data label", Target{Data: []string{":config.json"}}, "config.json", true},
+		{"data glob", Target{DataGlobs: []Glob{{Include: []string{"testdata/**"}}}}, "testdata/a/b.txt", true},
+		{"srcs are not data", Target{Srcs: []string{"config.json"}}, "config.json", false},
+		{"dynamic data", Target{DataDynamic: true}, "config.json", true},
+	}
+	for _, tt := range tests {
+		if got := tt.target.listsData("pkg", tt.file); got != tt.want {
+			t.Errorf("%s: listsData(%q) = %v, want %v", tt.name, tt.file, got, tt.want)
+		}
+	}
+}
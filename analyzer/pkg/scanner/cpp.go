@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// cppExtensions are the C and C++ source and header extensions
+var cppExtensions = map[string]bool{
+	".c": true, ".cc": true, ".cpp": true, ".cxx": true,
+	".h": true, ".hh": true, ".hpp": true, ".hxx": true,
+}
+
+// cppTestSuffixes are the basename suffixes of C and C++ test files
+var cppTestSuffixes = []string{"_test", "_unittest"}
+
+// cppState is empty: C and C++ packages are plain directories
+type cppState struct{}
+
+func (cppState) Key() string                { return "" }
+func (cppState) Child(name string) DirState { return cppState{} }
+
+// cppDetector counts C and C++ files per directory, like cc_* rules
+type cppDetector struct{}
+
+func (cppDetector) Language() Language { return LangCpp }
+func (cppDetector) Name() string       { return "C/C++" }
+
+func (cppDetector) RuleKinds() map[RuleRole][]string {
+	return map[RuleRole][]string{
+		RoleTest:    {"cc_test"},
+		RoleLibrary: {"cc_library"},
+		RoleBinary:  {"cc_binary"},
+	}
+}
+
+func (cppDetector) Owns(filename string) bool {
+	return cppExtensions[filepath.Ext(filename)]
+}
+
+func (cppDetector) EnterDir(parent DirState, dir *Dir) DirState {
+	return cppState{}
+}
+
+// ClassifyFile treats *_test.cc and *_unittest.cc, in any C or C++
+// extension, as tests; headers count as sources
+func (cppDetector) ClassifyFile(dir *Dir, filename string) (FileKind, ExclusionReason) {
+	stem := strings.TrimSuffix(filename, filepath.Ext(filename))
+	for _, suffix := range cppTestSuffixes {
+		if strings.HasSuffix(stem, suffix) {
+			return FileTest, ""
+		}
+	}
+	return FileSource, ""
+}
+
+func (cppDetector) InitPackage(dir *Dir, pkg *Package) {}
+
+func (cppDetector) Finish(dirs []*DirResult) ([]*Package, []*ExcludedPackage) {
+	var pkgs []*Package
+	for _, dr := range dirs {
+		if dr.Package != nil {
+			pkgs = append(pkgs, dr.Package)
+		}
+	}
+	return pkgs, nil
+}
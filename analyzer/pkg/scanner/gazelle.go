@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ExcludedByGazelle marks paths matched by a `# gazelle:exclude` directive
+	ExcludedByGazelle ExclusionReason = "gazelle-exclude"
+	// ExcludedByGazelleIgnore marks packages whose BUILD file has a
+	// `# gazelle:ignore` directive and no rules of the package's language,
+	// i.e. packages intentionally left unbuilt
+	ExcludedByGazelleIgnore ExclusionReason = "gazelle-ignore"
+)
+
+// gazelleDirective matches a `# gazelle:key value` line of a BUILD file
+var gazelleDirective = regexp.MustCompile(`^#\s*gazelle:(\w+)\s*(.*?)\s*$`)
+
+// gazelleConfig holds the gazelle directives in effect for a directory. As
+// in gazelle, directives in a BUILD file apply to its directory and every
+// directory below it, except ignore, which only applies to its own file.
+type gazelleConfig struct {
+	// excludes are repo-relative slash-separated path patterns
+	excludes []string
+	// prefix is the Go import path of prefixDir
+	prefix    string
+	prefixDir string
+	// goNamingConvention is "import", "go_default_library" or "import_alias"
+	goNamingConvention string
+}
+
+// child returns the directives in effect for a directory, adding those of
+// its BUILD file, and whether the file has a `# gazelle:ignore` directive.
+// Like gazelle, it reads BUILD.bazel in preference to BUILD.
+func (g *gazelleConfig) child(dir *Dir) (*gazelleConfig, bool) {
+	buildFile := buildFileName(dir.Entries)
+	if buildFile == "" {
+		return g, false
+	}
+	data, err := dir.ReadFile(buildFile)
+	if err != nil {
+		return g, false
+	}
+
+	base := filepath.ToSlash(dir.RelPath)
+	if base == "." {
+		base = ""
+	}
+	next := *g
+	changed, ignore := false, false
+	for _, line := range strings.Split(string(data), "\n") {
+		m := gazelleDirective.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		switch key, value := m[1], m[2]; key {
+		case "exclude":
+			if value != "" {
+				next.excludes = append(next.excludes[:len(next.excludes):len(next.excludes)], path.Join(base, value))
+				changed = true
+			}
+		case "ignore":
+			ignore = true
+		case "prefix":
+			next.prefix, next.prefixDir = value, base
+			changed = true
+		case "go_naming_convention":
+			next.goNamingConvention = value
+			changed = true
+		}
+	}
+	if !changed {
+		return g, ignore
+	}
+	return &next, ignore
+}
+
+// excluded reports whether a slash-separated repo-relative path matches an
+// exclude directive
+func (g *gazelleConfig) excluded(relSlash string) bool {
+	for _, pattern := range g.excludes {
+		if globMatch(pattern, relSlash) {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies the directives for cache fingerprints
+func (g *gazelleConfig) key() string {
+	return strings.Join(g.excludes, ",") + ";" + g.prefix + ";" + g.prefixDir + ";" + g.goNamingConvention
+}
+
+// importPath returns the Go import path gazelle assigns a directory, or ""
+// without a prefix directive
+func (g *gazelleConfig) importPath(relSlash string) string {
+	if g.prefix == "" {
+		return ""
+	}
+	if relSlash == "." {
+		relSlash = ""
+	}
+	rel := relSlash
+	if g.prefixDir != "" {
+		rel = strings.TrimPrefix(strings.TrimPrefix(relSlash, g.prefixDir), "/")
+	}
+	return path.Join(g.prefix, rel)
+}
+
+// applyGazelle excludes the packages of directories whose BUILD file gazelle
+// ignores and that have no rules of the package's language, and records the
+// import path and naming convention gazelle uses for Go packages
+func applyGazelle(dirs []*dirPackages) {
+	for _, dp := range dirs {
+		if dp.gazelle == nil {
+			continue
+		}
+		if dp.gazelleIgnore {
+			for lang := range dp.pkgs {
+				if dp.targets.count(lang, RoleTest)+dp.targets.count(lang, RoleLibrary)+dp.targets.count(lang, RoleBinary) > 0 {
+					continue
+				}
+				delete(dp.pkgs, lang)
+				delete(dp.files, lang)
+				dp.exclude(lang, ExcludedByGazelleIgnore)
+			}
+		}
+		if pkg := dp.pkgs[LangGo]; pkg != nil {
+			pkg.GazelleImportPath = dp.gazelle.importPath(filepath.ToSlash(dp.relPath))
+			pkg.GoNamingConvention = dp.gazelle.goNamingConvention
+		}
+	}
+}
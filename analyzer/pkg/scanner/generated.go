@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"bufio"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generatedHeader matches the marker code generators write near the top of
+// their output: Go's "Code generated ... DO NOT EDIT.", which generators for
+// other languages follow too, protoc's Python header and "@generated"
+var generatedHeader = regexp.MustCompile(`^(?://|#|/?\*+|--|"""|''')?\s*(?:Code generated .* DO NOT EDIT\.?|Generated by the protocol buffer compiler\.\s+DO NOT EDIT!|@generated\b)`)
+
+// generatedHeaderLines is how many lines of a file are searched for a
+// generated-code header
+const generatedHeaderLines = 40
+
+// generatedNames are file name patterns of generated code besides protoc
+// output, which often lacks a header in older protoc versions
+var generatedNames = []string{"*.pb.gw.go", "zz_generated*"}
+
+// defaultGeneratedPatterns returns the built-in generated file patterns
+func defaultGeneratedPatterns() []string {
+	patterns := make([]string, 0, len(protoGeneratedSuffixes)+len(generatedNames))
+	for _, gen := range protoGeneratedSuffixes {
+		patterns = append(patterns, "*"+gen.suffix)
+	}
+	return append(patterns, generatedNames...)
+}
+
+// generatedFile reports whether a source file of a directory is generated
+// code, by its name or its header
+func (s *Scanner) generatedFile(dp *dirPackages, filename string) bool {
+	relSlash := path.Join(filepath.ToSlash(dp.relPath), filename)
+	for _, pattern := range s.generatedPatterns {
+		name := filename
+		if strings.Contains(pattern, "/") {
+			name = relSlash
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	file, err := s.fsys.Open(dp.file(filename))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(file)
+	for i := 0; i < generatedHeaderLines && sc.Scan(); i++ {
+		if generatedHeader.MatchString(strings.TrimSpace(sc.Text())) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gitObjectID is the blob id a gitFS file's Sys() returns. Scan cache
+// fingerprints use it in place of the size and mtime.
+type gitObjectID string
+
+// gitFS is a read-only file system of the files git tracks under a
+// directory, either in the index or in a tree-ish. File contents come from
+// git's object store, so neither untracked files nor uncommitted edits in
+// the working tree show up in it.
+type gitFS struct {
+	*treeFS
+	cat *gitCatFile
+}
+
+// openGitIndex lists the files staged in the index of the repository
+// containing dir, relative to dir
+func openGitIndex(ctx context.Context, dir string) (*gitFS, error) {
+	out, err := runGit(ctx, dir, "ls-files", "-z", "--stage")
+	if err != nil {
+		return nil, err
+	}
+	// "<mode> <oid> <stage>\t<path>"; unmerged paths appear once per stage
+	return newGitFS(ctx, dir, out, func(meta string) (mode, oid string, ok bool) {
+		fields := strings.Fields(meta)
+		if len(fields) != 3 {
+			return "", "", false
+		}
+		return fields[0], fields[1], true
+	})
+}
+
+// openGitTree lists the files of a tree-ish, e.g. a commit, branch or tag,
+// under dir
+func openGitTree(ctx context.Context, dir, treeish string) (*gitFS, error) {
+	out, err := runGit(ctx, dir, "ls-tree", "-r", "-z", treeish)
+	if err != nil {
+		return nil, err
+	}
+	// "<mode> <type> <oid>\t<path>"
+	return newGitFS(ctx, dir, out, func(meta string) (mode, oid string, ok bool) {
+		fields := strings.Fields(meta)
+		if len(fields) != 3 || fields[1] != "blob" {
+			return "", "", false
+		}
+		return fields[0], fields[2], true
+	})
+}
+
+// newGitFS builds the file system from NUL-terminated `git ls-files` or
+// `git ls-tree` records, whose metadata parse picks apart. Submodules are
+// left out.
+func newGitFS(ctx context.Context, dir string, listing []byte, parse func(meta string) (mode, oid string, ok bool)) (*gitFS, error) {
+	cat, err := startGitCatFile(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	g := &gitFS{treeFS: newTreeFS(), cat: cat}
+	for _, record := range bytes.Split(listing, []byte{0}) {
+		meta, name, ok := strings.Cut(string(record), "\t")
+		if !ok {
+			continue
+		}
+		modeStr, oid, ok := parse(meta)
+		if !ok {
+			continue
+		}
+		var mode fs.FileMode
+		switch modeStr {
+		case "100644":
+			mode = 0644
+		case "100755":
+			mode = 0755
+		case "120000":
+			mode = fs.ModeSymlink | 0777
+		default:
+			continue // 160000 is a submodule
+		}
+		g.addFile(name, &treeFile{
+			mode: mode,
+			size: -1,
+			sys:  gitObjectID(oid),
+			read: func() ([]byte, error) { return cat.read(oid) },
+		})
+	}
+	g.finish()
+	return g, nil
+}
+
+// Close stops the git process reading file contents
+func (g *gitFS) Close() error {
+	return g.cat.close()
+}
+
+// gitCatFile reads blobs through a long-running `git cat-file --batch`,
+// one request at a time
+type gitCatFile struct {
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *bufio.Reader
+}
+
+func startGitCatFile(ctx context.Context, dir string) (*gitCatFile, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return &gitCatFile{cmd: cmd, stdin: stdin, out: bufio.NewReader(stdout)}, nil
+}
+
+// read returns the content of a blob
+func (c *gitCatFile) read(oid string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := io.WriteString(c.stdin, oid+"\n"); err != nil {
+		return nil, err
+	}
+	// "<oid> <type> <size>\n<content>\n", or "<oid> missing\n"
+	header, err := c.out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: bad header %q", strings.TrimSpace(header))
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(c.out, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+func (c *gitCatFile) close() error {
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+// runGit runs a git command in dir and returns its standard output
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo writes files into a new git repository and stages them with
+// `git add -f`, so files matching .gitignore are tracked too
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	repo := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-f", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	return repo
+}
+
+func TestGitScansIncludeIgnoredTrackedFiles(t *testing.T) {
+	repo := gitRepo(t, map[string]string{
+		".gitignore":   "*.gen.py\nout/\n",
+		"lib/a.py":     "x = 1\n",
+		"lib/b.gen.py": "y = 2\n",
+		"out/tool.py":  "print()\n",
+		"out/BUILD":    `py_binary(name = "tool", srcs = ["tool.py"])` + "\n",
+		"lib/BUILD":    `py_library(name = "lib", srcs = ["a.py", "b.gen.py"])` + "\n",
+	})
+	// An untracked file matching .gitignore stays out of git scans anyway
+	if err := os.WriteFile(filepath.Join(repo, "lib", "c.gen.py"), []byte("z = 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"index", WithGitIndex()},
+		{"tree", WithGitTree("HEAD")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewScanner(repo, tt.opt).Scan()
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			sources := make(map[string]int)
+			for _, pkg := range result.Packages(LangPython) {
+				sources[filepath.ToSlash(pkg.RelPath)] = pkg.SourceFileCount
+			}
+			if sources["lib"] != 2 || sources["out"] != 1 {
+				t.Errorf("Python source files per package = %v, want lib:2 out:1", sources)
+			}
+			for _, ep := range result.ExcludedPackages {
+				if ep.Reason == ExcludedByGitignore {
+					t.Errorf("%s excluded by .gitignore in a scan of tracked files", ep.RelPath)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package scanner
+
+import (
+	"bufio"
+	"go/build/constraint"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+const (
+	ExcludedGoTestdata        ExclusionReason = "go-testdata"
+	ExcludedGoUnderscore      ExclusionReason = "go-underscore"
+	ExcludedGoBuildConstraint ExclusionReason = "go-build-constraint"
+	ExcludedGoNoModule        ExclusionReason = "go-no-module"
+	ExcludedGoWorkspace       ExclusionReason = "go-work"
+)
+
+// goModule is a module declared by a go.mod file
+type goModule struct {
+	dir  string // slash-separated repo-relative module root, "." for the repo root
+	path string // module path from the module directive
+}
+
+// goWorkspace is the set of modules listed by a go.work file
+type goWorkspace struct {
+	dir  string
+	uses map[string]bool // slash-separated repo-relative module roots
+}
+
+// goState is the Go toolchain context a directory inherits from its parents
+type goState struct {
+	module    *goModule
+	workspace *goWorkspace
+	// dirExcluded is set below testdata and _-prefixed directories, which
+	// the go command ignores along with everything beneath them
+	dirExcluded ExclusionReason
+	// outsideWorkspace is set inside a module that the enclosing go.work
+	// does not use
+	outsideWorkspace bool
+	// moduleRoot is set in the directory holding the module's go.mod
+	moduleRoot bool
+}
+
+// Key identifies the state for cache fingerprints
+func (g goState) Key() string {
+	var b strings.Builder
+	if g.module != nil {
+		b.WriteString(g.module.dir + "=" + g.module.path)
+	}
+	b.WriteString(";" + string(g.dirExcluded))
+	if g.outsideWorkspace {
+		b.WriteString(";outside-work")
+	}
+	return b.String()
+}
+
+// exclusion returns why Go files in the directory are ignored, if they are
+func (g goState) exclusion() ExclusionReason {
+	if g.dirExcluded != "" {
+		return g.dirExcluded
+	}
+	if g.outsideWorkspace {
+		return ExcludedGoWorkspace
+	}
+	return ""
+}
+
+// enterDir applies a directory's own go.work and go.mod files to the state
+// inherited from its parent
+func (g goState) enterDir(dir *Dir) goState {
+	relSlash := filepath.ToSlash(dir.RelPath)
+	var hasGoMod, hasGoWork bool
+	for _, entry := range dir.Entries {
+		switch entry.Name() {
+		case "go.mod":
+			hasGoMod = !entry.IsDir()
+		case "go.work":
+			hasGoWork = !entry.IsDir()
+		}
+	}
+	if g.dirExcluded != "" {
+		return g
+	}
+
+	if hasGoWork {
+		if ws := loadGoWork(dir, relSlash); ws != nil {
+			g.workspace = ws
+		}
+	}
+	if hasGoMod {
+		data, err := dir.ReadFile("go.mod")
+		if err == nil {
+			g.module = &goModule{dir: relSlash, path: modfile.ModulePath(data)}
+			g.outsideWorkspace = g.workspace != nil && !g.workspace.uses[relSlash]
+		}
+	}
+	g.moduleRoot = hasGoMod
+	return g
+}
+
+// Child returns the state for a subdirectory, applying the go command's
+// testdata and underscore directory rules
+func (g goState) Child(name string) DirState {
+	g.moduleRoot = false
+	if g.dirExcluded == "" {
+		switch {
+		case name == "testdata":
+			g.dirExcluded = ExcludedGoTestdata
+		case strings.HasPrefix(name, "_"):
+			g.dirExcluded = ExcludedGoUnderscore
+		}
+	}
+	return g
+}
+
+// importPath returns the import path of a package directory, or "" outside
+// any module
+func (g goState) importPath(relSlash string) string {
+	if g.module == nil || g.module.path == "" {
+		return ""
+	}
+	if relSlash == g.module.dir {
+		return g.module.path
+	}
+	rel := relSlash
+	if g.module.dir != "." {
+		rel = strings.TrimPrefix(relSlash, g.module.dir+"/")
+	}
+	return path.Join(g.module.path, rel)
+}
+
+func loadGoWork(dir *Dir, relSlash string) *goWorkspace {
+	data, err := dir.ReadFile("go.work")
+	if err != nil {
+		return nil
+	}
+	wf, err := modfile.ParseWork(dir.file("go.work"), data, nil)
+	if err != nil {
+		return nil
+	}
+	ws := &goWorkspace{dir: relSlash, uses: make(map[string]bool)}
+	for _, use := range wf.Use {
+		ws.uses[path.Join(relSlash, filepath.ToSlash(use.Path))] = true
+	}
+	return ws
+}
+
+// goDetector implements the go command's package rules
+type goDetector struct {
+	// buildTags are the custom build tags from the config
+	buildTags map[string]bool
+}
+
+func (d *goDetector) Language() Language { return LangGo }
+func (d *goDetector) Name() string       { return "Go" }
+
+func (d *goDetector) RuleKinds() map[RuleRole][]string {
+	return map[RuleRole][]string{
+		RoleTest:    {"go_test"},
+		RoleLibrary: {"go_library"},
+		RoleBinary:  {"go_binary"},
+	}
+}
+
+func (d *goDetector) Owns(filename string) bool {
+	return strings.HasSuffix(filename, ".go")
+}
+
+func (d *goDetector) EnterDir(parent DirState, dir *Dir) DirState {
+	g, _ := parent.(goState)
+	return g.enterDir(dir)
+}
+
+// ClassifyFile returns why the go command would leave a .go file out of its
+// package, or whether it is a test
+func (d *goDetector) ClassifyFile(dir *Dir, filename string) (FileKind, ExclusionReason) {
+	g := dir.State.(goState)
+	if reason := g.exclusion(); reason != "" {
+		return 0, reason
+	}
+	if strings.HasPrefix(filename, "_") || strings.HasPrefix(filename, ".") {
+		return 0, ExcludedGoUnderscore
+	}
+	if expr := readGoConstraint(dir, filename); expr != nil && !d.constraintSatisfiable(expr) {
+		return 0, ExcludedGoBuildConstraint
+	}
+	if strings.HasSuffix(filename, "_test.go") {
+		return FileTest, ""
+	}
+	return FileSource, ""
+}
+
+func (d *goDetector) InitPackage(dir *Dir, pkg *Package) {
+	g := dir.State.(goState)
+	if g.module != nil {
+		pkg.ModulePath = g.module.path
+		pkg.ImportPath = g.importPath(filepath.ToSlash(dir.RelPath))
+	}
+}
+
+// Finish drops packages outside any module, but only when the repo uses
+// modules at all, so GOPATH-style and Bazel-only Go trees still count
+func (d *goDetector) Finish(dirs []*DirResult) ([]*Package, []*ExcludedPackage) {
+	hasGoModules := false
+	for _, dr := range dirs {
+		if g, _ := dr.State.(goState); g.moduleRoot {
+			hasGoModules = true
+			break
+		}
+	}
+
+	var (
+		pkgs     []*Package
+		excluded []*ExcludedPackage
+	)
+	for _, dr := range dirs {
+		if dr.Package == nil {
+			continue
+		}
+		if hasGoModules && dr.Package.ModulePath == "" {
+			excluded = append(excluded, &ExcludedPackage{
+				RelPath:  dr.RelPath,
+				Language: LangGo,
+				Reason:   ExcludedGoNoModule,
+			})
+			continue
+		}
+		pkgs = append(pkgs, dr.Package)
+	}
+	return pkgs, excluded
+}
+
+// readGoConstraint returns the build constraint in a Go file's header, if any
+func readGoConstraint(dir *Dir, filename string) constraint.Expr {
+	file, err := dir.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var plusBuild constraint.Expr
+	inBlock := false
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case inBlock:
+			inBlock = !strings.Contains(line, "*/")
+			continue
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "/*"):
+			inBlock = !strings.Contains(line, "*/")
+			continue
+		case !strings.HasPrefix(line, "//"):
+			// Constraints must appear before the package clause
+			return plusBuild
+		}
+
+		if constraint.IsGoBuild(line) {
+			if expr, err := constraint.Parse(line); err == nil {
+				return expr
+			}
+		} else if constraint.IsPlusBuild(line) {
+			if expr, err := constraint.Parse(line); err == nil {
+				if plusBuild == nil {
+					plusBuild = expr
+				} else {
+					plusBuild = &constraint.AndExpr{X: plusBuild, Y: expr}
+				}
+			}
+		}
+	}
+	return plusBuild
+}
+
+var (
+	knownGoOS = []string{
+		"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios",
+		"js", "linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows",
+	}
+	knownGoArch = []string{
+		"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le",
+		"mipsle", "ppc64", "ppc64le", "riscv64", "s390x", "wasm",
+	}
+	unixGoOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+		"hurd": true, "illumos": true, "ios": true, "linux": true, "netbsd": true,
+		"openbsd": true, "solaris": true,
+	}
+)
+
+// constraintSatisfiable reports whether a build constraint holds for some
+// GOOS/GOARCH, with or without cgo, given only the default build tags plus
+// the configured goBuildTags. Files guarded by custom tags such as "ignore"
+// or "tools" are therefore excluded, as they are from a plain `go build`.
+func (d *goDetector) constraintSatisfiable(expr constraint.Expr) bool {
+	for _, goos := range knownGoOS {
+		for _, goarch := range knownGoArch {
+			for _, cgo := range []bool{true, false} {
+				ok := expr.Eval(func(tag string) bool {
+					switch {
+					case tag == goos, tag == goarch:
+						return true
+					case tag == "linux":
+						return goos == "android"
+					case tag == "darwin":
+						return goos == "ios"
+					case tag == "solaris":
+						return goos == "illumos"
+					case tag == "unix":
+						return unixGoOS[goos]
+					case tag == "cgo":
+						return cgo
+					case tag == "gc":
+						return true
+					case strings.HasPrefix(tag, "go1."):
+						return true
+					}
+					return d.buildTags[tag]
+				})
+				if ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
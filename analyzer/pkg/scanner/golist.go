@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// goListTimeout bounds a single `go list` invocation
+const goListTimeout = 5 * time.Minute
+
+// GoBackend selects how Go packages are discovered
+type GoBackend string
+
+const (
+	// GoBackendWalk classifies .go files found by the directory walker
+	GoBackendWalk GoBackend = "walk"
+	// GoBackendGoList asks `go list -json -test ./...` in every module
+	GoBackendGoList GoBackend = "golist"
+)
+
+// ExcludedGoList marks directories whose .go files `go list` did not report
+// as a package, e.g. because every file is constrained to another platform
+const ExcludedGoList ExclusionReason = "go-list"
+
+// goListPackage is the subset of `go list -json` output we use
+type goListPackage struct {
+	Dir          string
+	ImportPath   string
+	ForTest      string
+	Module       *struct{ Path string }
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	EmbedFiles   []string
+	Imports      []string
+}
+
+// applyGoList replaces the walker's Go packages with `go list` results, one
+// module at a time. It returns the backend actually used: without a go
+// binary on PATH the walker results are kept for every module, and a module
+// where `go list` fails keeps its walker results too. Unless `go list`
+// succeeded in at least one module, every Go package comes from the walker.
+func (s *Scanner) applyGoList(ctx context.Context, dirs []*dirPackages) GoBackend {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: go not found on PATH, using the directory walker for Go packages\n")
+		return GoBackendWalk
+	}
+
+	byPath := make(map[string]*dirPackages, len(dirs))
+	var modules []*dirPackages
+	for _, dp := range dirs {
+		byPath[dp.path] = dp
+		if g := dp.goState(); g.moduleRoot && dp.excluded == "" && g.exclusion() == "" {
+			modules = append(modules, dp)
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].relPath < modules[j].relPath
+	})
+
+	backend := GoBackendWalk
+	for _, mod := range modules {
+		pkgs, err := s.goList(ctx, goBin, mod.path)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Warning: go list failed in %s, using the directory walker: %v\n", mod.relPath, err)
+			}
+			continue
+		}
+		backend = GoBackendGoList
+
+		// Drop the walker's packages for this module; go list is authoritative
+		moduleDir := mod.goState().module.dir
+		for _, dp := range dirs {
+			if g := dp.goState(); dp.pkgs[LangGo] != nil && g.module != nil && g.module.dir == moduleDir {
+				delete(dp.pkgs, LangGo)
+				delete(dp.files, LangGo)
+				dp.exclude(LangGo, ExcludedGoList)
+			}
+		}
+
+		// go list reports symlink-resolved directories
+		realDir, err := filepath.EvalSymlinks(mod.path)
+		if err != nil {
+			realDir = mod.path
+		}
+		for _, lp := range pkgs {
+			rel, err := filepath.Rel(realDir, lp.Dir)
+			if err != nil {
+				continue
+			}
+			dp, ok := byPath[filepath.Join(mod.path, rel)]
+			if !ok || dp.excluded != "" {
+				continue // directories the walker skipped or ignored
+			}
+			pkg := dp.newPackage(LangGo)
+			pkg.ImportPath = lp.ImportPath
+			if lp.Module != nil {
+				pkg.ModulePath = lp.Module.Path
+			}
+			var sources, generated []string
+			for _, name := range append(append([]string(nil), lp.GoFiles...), lp.CgoFiles...) {
+				if s.generatedFile(dp, name) {
+					pkg.GeneratedFileCount++
+					generated = append(generated, name)
+				} else {
+					sources = append(sources, name)
+				}
+			}
+			pkg.SourceFileCount = len(sources)
+			pkg.TestFileCount = len(lp.TestGoFiles) + len(lp.XTestGoFiles)
+			pkg.HasTestFiles = pkg.TestFileCount > 0
+			pkg.CgoFileCount = len(lp.CgoFiles)
+			pkg.EmbedFileCount = len(lp.EmbedFiles)
+			pkg.Imports = lp.Imports
+			if dp.pkgs == nil {
+				dp.pkgs = make(map[Language]*Package)
+			}
+			dp.pkgs[LangGo] = pkg
+			if dp.files == nil {
+				dp.files = make(map[Language]*dirFiles)
+			}
+			dp.files[LangGo] = &dirFiles{
+				Sources:   sources,
+				Tests:     append(append([]string(nil), lp.TestGoFiles...), lp.XTestGoFiles...),
+				Generated: generated,
+			}
+		}
+	}
+
+	return backend
+}
+
+// goState returns the directory's Go toolchain context
+func (dp *dirPackages) goState() goState {
+	g, _ := dp.states[LangGo].(goState)
+	return g
+}
+
+// goList runs `go list -e -json -test ./...` in a module root and returns
+// its packages, without test variants and generated test mains
+func (s *Scanner) goList(ctx context.Context, goBin, moduleDir string) ([]*goListPackage, error) {
+	args := []string{"list", "-e", "-json", "-test"}
+	if len(s.goBuildTags) > 0 {
+		tags := make([]string, 0, len(s.goBuildTags))
+		for tag := range s.goBuildTags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		args = append(args, "-tags="+strings.Join(tags, ","))
+	}
+	args = append(args, "./...")
+
+	ctx, cancel := context.WithTimeout(ctx, goListTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, goBin, args...)
+	cmd.Dir = moduleDir
+	// List each module on its own and never touch the network; with -e,
+	// packages with unresolvable imports are still reported with their files.
+	// GOFLAGS is left alone so a module's vendor directory is honored.
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOPROXY=off")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, runErr := cmd.Output()
+
+	var pkgs []*goListPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var lp goListPackage
+		if err := dec.Decode(&lp); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding go list output: %w", err)
+		}
+		if lp.ForTest != "" || strings.HasSuffix(lp.ImportPath, ".test") || lp.Dir == "" {
+			continue
+		}
+		pkgs = append(pkgs, &lp)
+	}
+
+	if runErr != nil && len(pkgs) == 0 {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", runErr, msg)
+		}
+		return nil, runErr
+	}
+	return pkgs, nil
+}
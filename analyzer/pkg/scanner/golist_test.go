@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeGo puts a go binary on PATH that records its GOFLAGS and then prints
+// the packages as `go list -json` would, or fails when pkgs is nil
+func fakeGo(t *testing.T, pkgs []goListPackage) (flagsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go binary is a shell script")
+	}
+	bin := t.TempDir()
+	flagsFile = filepath.Join(bin, "goflags")
+	script := "#!/bin/sh\nprintf '%s' \"$GOFLAGS\" > " + flagsFile + "\n"
+	if pkgs == nil {
+		script += "echo 'go: updates to go.mod needed' >&2\nexit 1\n"
+	} else {
+		var out []byte
+		for _, lp := range pkgs {
+			data, err := json.Marshal(lp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, data...)
+		}
+		output := filepath.Join(bin, "output.json")
+		if err := os.WriteFile(output, out, 0644); err != nil {
+			t.Fatal(err)
+		}
+		script += "cat " + output + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(bin, "go"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GOFLAGS", "")
+	return flagsFile
+}
+
+// goModuleRepo writes a module with a package at its root and one in sub/,
+// or just the packages without a go.mod when module is false
+func goModuleRepo(t *testing.T, module bool) string {
+	t.Helper()
+	repo, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":    "module example.com/m\n\ngo 1.21\n",
+		"a.go":      "package m\n",
+		"a_test.go": "package m\n",
+		"sub/b.go":  "package sub\n",
+	}
+	if !module {
+		delete(files, "go.mod")
+	}
+	for name, content := range files {
+		p := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestGoListBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		// noModule leaves out the go.mod
+		noModule bool
+		// pkgs is what go list reports, nil when it fails
+		pkgs []goListPackage
+		// want maps each reported package to its imports
+		want         map[string][]string
+		wantExcluded []string
+		wantBackend  GoBackend
+	}{
+		{
+			name: "go list output",
+			pkgs: []goListPackage{
+				{ImportPath: "example.com/m", Module: &struct{ Path string }{"example.com/m"}, GoFiles: []string{"a.go"}, TestGoFiles: []string{"a_test.go"}, Imports: []string{"fmt"}},
+				{ImportPath: "example.com/m [example.com/m.test]", ForTest: "example.com/m", GoFiles: []string{"a.go", "a_test.go"}},
+				{ImportPath: "example.com/m.test", GoFiles: []string{"_testmain.go"}},
+			},
+			want:         map[string][]string{".": {"fmt"}},
+			wantExcluded: []string{"sub"},
+			wantBackend:  GoBackendGoList,
+		},
+		{
+			name:        "fallback to the walker",
+			want:        map[string][]string{".": nil, "sub": nil},
+			wantBackend: GoBackendWalk,
+		},
+		{
+			name:        "no modules",
+			noModule:    true,
+			pkgs:        []goListPackage{},
+			want:        map[string][]string{".": nil, "sub": nil},
+			wantBackend: GoBackendWalk,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := goModuleRepo(t, !tt.noModule)
+			for i := range tt.pkgs {
+				if tt.pkgs[i].ForTest == "" {
+					tt.pkgs[i].Dir = repo
+				}
+			}
+			flagsFile := fakeGo(t, tt.pkgs)
+
+			result, err := NewScanner(repo, WithGoBackend(GoBackendGoList)).Scan()
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if flags, err := os.ReadFile(flagsFile); err == nil && len(flags) > 0 {
+				t.Errorf("go list ran with GOFLAGS=%q, want it left alone", flags)
+			}
+			if result.GoBackend != tt.wantBackend {
+				t.Errorf("GoBackend = %q, want %q", result.GoBackend, tt.wantBackend)
+			}
+
+			got := make(map[string][]string)
+			for _, pkg := range result.Packages(LangGo) {
+				got[pkg.RelPath] = pkg.Imports
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Go packages = %v, want %v", got, tt.want)
+			}
+			for rel, imports := range tt.want {
+				if gotImports, ok := got[rel]; !ok || len(gotImports) != len(imports) {
+					t.Errorf("package %s imports %v (found %v), want %v", rel, gotImports, ok, imports)
+				}
+			}
+
+			var excluded []string
+			for _, ep := range result.ExcludedPackages {
+				if ep.Language == LangGo && ep.Reason == ExcludedGoList {
+					excluded = append(excluded, ep.RelPath)
+				}
+			}
+			if len(excluded) != len(tt.wantExcluded) || (len(excluded) > 0 && excluded[0] != tt.wantExcluded[0]) {
+				t.Errorf("excluded by go list = %v, want %v", excluded, tt.wantExcluded)
+			}
+		})
+	}
+}
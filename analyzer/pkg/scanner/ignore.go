@@ -0,0 +1,208 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern line of a .gitignore file
+type ignoreRule struct {
+	segments []string // pattern split on "/"
+	negate   bool     // "!pattern" re-includes a previously excluded path
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // pattern contains a "/" and is relative to the .gitignore directory
+}
+
+// ignoreFile holds the rules of one .gitignore file
+type ignoreFile struct {
+	base  string // slash-separated repo-relative directory of the file, "" for the root
+	hash  string // content hash, used to fingerprint cached directories
+	rules []ignoreRule
+}
+
+// gitignore is the chain of .gitignore files in effect for a directory,
+// outermost first
+type gitignore struct {
+	files []*ignoreFile
+}
+
+// loadIgnoreFile parses a .gitignore-style file. A missing file yields nil.
+func loadIgnoreFile(fsys fs.FS, filename, base string) *ignoreFile {
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return nil
+	}
+
+	sum := sha256.Sum256(data)
+	f := &ignoreFile{base: base, hash: hex.EncodeToString(sum[:])}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		if rule, ok := parseIgnoreRule(sc.Text()); ok {
+			f.rules = append(f.rules, rule)
+		}
+	}
+	if len(f.rules) == 0 {
+		return nil
+	}
+	return f
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the file's directory
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	// path.Match spells negated character classes [^...], git spells them [!...]
+	line = strings.ReplaceAll(line, "[!", "[^")
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// match reports whether rel (relative to the rule's .gitignore) matches the rule
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], parts[len(parts)-1])
+		return ok
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more whole segments. A trailing "**" matches at least one,
+// so "foo/**" matches everything inside foo but not foo itself.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if len(pattern) == 1 && pattern[0] == "**" {
+			return len(parts) > 0
+		}
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// child returns the chain for a subdirectory that may add its own .gitignore
+func (g *gitignore) child(f *ignoreFile) *gitignore {
+	if f == nil {
+		return g
+	}
+	files := make([]*ignoreFile, len(g.files), len(g.files)+1)
+	copy(files, g.files)
+	return &gitignore{files: append(files, f)}
+}
+
+// key identifies the chain's files and contents
+func (g *gitignore) key() string {
+	var b strings.Builder
+	for _, f := range g.files {
+		b.WriteString(f.base)
+		b.WriteByte(':')
+		b.WriteString(f.hash)
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// ignored reports whether the slash-separated repo-relative path is excluded.
+// Deeper .gitignore files take precedence, and within a file the last
+// matching pattern wins.
+func (g *gitignore) ignored(relPath string, isDir bool) bool {
+	for i := len(g.files) - 1; i >= 0; i-- {
+		f := g.files[i]
+		rel := relPath
+		if f.base != "" {
+			if !strings.HasPrefix(relPath, f.base+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(relPath, f.base+"/")
+		}
+		for j := len(f.rules) - 1; j >= 0; j-- {
+			if f.rules[j].match(rel, isDir) {
+				return !f.rules[j].negate
+			}
+		}
+	}
+	return false
+}
+
+// loadBazelignore reads the directories listed in the .bazelignore of the
+// workspace at dir, relative to the repository
+func loadBazelignore(fsys fs.FS, dir string) []string {
+	file, err := fsys.Open(path.Join(dir, ".bazelignore"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var dirs []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dirs = append(dirs, path.Join(dir, filepath.ToSlash(line)))
+	}
+	return dirs
+}
+
+// bazelignored reports whether a slash-separated repo-relative directory is
+// inside one of the .bazelignore entries
+func bazelignored(dirs []string, relPath string) bool {
+	for _, d := range dirs {
+		if relPath == d || strings.HasPrefix(relPath, d+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package scanner
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestGitignore(t *testing.T) {
+	tests := []struct {
+		name string
+		// files maps .gitignore locations to their content
+		files map[string]string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"name matches at any depth", map[string]string{".gitignore": "*.log\n"}, "a/b/debug.log", false, true},
+		{"name matches directories too", map[string]string{".gitignore": "build\n"}, "a/build", true, true},
+		{"anchored with leading slash", map[string]string{".gitignore": "/build\n"}, "a/build", true, false},
+		{"anchored at the root", map[string]string{".gitignore": "/build\n"}, "build", true, true},
+		{"anchored by inner slash", map[string]string{".gitignore": "docs/*.md\n"}, "docs/a.md", false, true},
+		{"inner slash does not match deeper", map[string]string{".gitignore": "docs/*.md\n"}, "x/docs/a.md", false, false},
+		{"dirOnly skips files", map[string]string{".gitignore": "out/\n"}, "out", false, false},
+		{"dirOnly matches directories", map[string]string{".gitignore": "out/\n"}, "a/out", true, true},
+		{"negation re-includes", map[string]string{".gitignore": "*.go\n!keep.go\n"}, "keep.go", false, false},
+		{"last match wins", map[string]string{".gitignore": "!keep.go\n*.go\n"}, "keep.go", false, true},
+		{"leading ** matches any depth", map[string]string{".gitignore": "**/gen/*.py\n"}, "a/b/gen/x.py", false, true},
+		{"leading ** matches the root", map[string]string{".gitignore": "**/gen/*.py\n"}, "gen/x.py", false, true},
+		{"inner ** matches zero segments", map[string]string{".gitignore": "a/**/b\n"}, "a/b", true, true},
+		{"inner ** matches several segments", map[string]string{".gitignore": "a/**/b\n"}, "a/x/y/b", true, true},
+		{"trailing ** matches contents", map[string]string{".gitignore": "foo/**\n"}, "foo/bar/baz.go", false, true},
+		{"trailing ** does not match the directory", map[string]string{".gitignore": "foo/**\n"}, "foo", true, false},
+		{"trailing ** with negation", map[string]string{".gitignore": "foo/**\n!foo/keep.go\n"}, "foo/keep.go", false, false},
+		{"nested file applies below its directory", map[string]string{"sub/.gitignore": "*.tmp\n"}, "sub/a/x.tmp", false, true},
+		{"nested file does not apply elsewhere", map[string]string{"sub/.gitignore": "*.tmp\n"}, "other/x.tmp", false, false},
+		{"nested anchored rule", map[string]string{"sub/.gitignore": "/gen\n"}, "sub/gen", true, true},
+		{"deeper file takes precedence", map[string]string{".gitignore": "*.go\n", "sub/.gitignore": "!*.go\n"}, "sub/a.go", false, false},
+		{"comment", map[string]string{".gitignore": "# a.go\n"}, "# a.go", false, false},
+		{"escaped hash", map[string]string{".gitignore": "\\#a.go\n"}, "#a.go", false, true},
+		{"escaped bang", map[string]string{".gitignore": "\\!a.go\n"}, "!a.go", false, true},
+		{"escaped trailing space", map[string]string{".gitignore": "a\\ \n"}, "a ", false, true},
+		{"unescaped trailing space", map[string]string{".gitignore": "a  \n"}, "a", false, true},
+		{"negated character class", map[string]string{".gitignore": "[!a].go\n"}, "b.go", false, true},
+		{"negated character class excludes", map[string]string{".gitignore": "[!a].go\n"}, "a.go", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, content := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(content)}
+			}
+			g := &gitignore{}
+			for _, base := range []string{"", "sub"} {
+				name := ".gitignore"
+				if base != "" {
+					name = base + "/.gitignore"
+				}
+				g = g.child(loadIgnoreFile(fsys, name, base))
+			}
+			if got := g.ignored(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package scanner
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// jvmBuildFiles mark the root of a Maven or Gradle module
+var jvmBuildFiles = map[string]string{
+	"pom.xml":          "maven",
+	"build.gradle":     "gradle",
+	"build.gradle.kts": "gradle",
+}
+
+// jvmState is the Maven or Gradle module a directory belongs to
+type jvmState struct {
+	moduleRoot string // slash-separated repo-relative module root, "" outside any module
+	buildTool  string
+}
+
+// Key identifies the state for cache fingerprints
+func (j jvmState) Key() string {
+	return j.moduleRoot + ";" + j.buildTool
+}
+
+// Child returns the state for a subdirectory, which inherits the module
+func (j jvmState) Child(name string) DirState {
+	return j
+}
+
+// jvmSourceSet splits a directory in the standard Maven/Gradle layout,
+// <module>/src/<set>/{java,kotlin}/..., into the module root and the source
+// set name. ok is false outside that layout.
+func jvmSourceSet(relSlash string) (root, set string, ok bool) {
+	parts := strings.Split(relSlash, "/")
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] != "src" || (parts[i+2] != "java" && parts[i+2] != "kotlin") {
+			continue
+		}
+		root = path.Join(parts[:i]...)
+		if root == "" {
+			root = "."
+		}
+		return root, parts[i+1], true
+	}
+	return "", "", false
+}
+
+// isJVMTestName reports whether a class name matches the Maven Surefire
+// default test patterns
+func isJVMTestName(filename string) bool {
+	name := strings.TrimSuffix(strings.TrimSuffix(filename, ".java"), ".kt")
+	return strings.HasPrefix(name, "Test") ||
+		strings.HasSuffix(name, "Test") ||
+		strings.HasSuffix(name, "Tests") ||
+		strings.HasSuffix(name, "TestCase")
+}
+
+// javaDetector groups Java and Kotlin files by Maven or Gradle module
+type javaDetector struct{}
+
+func (javaDetector) Language() Language { return LangJava }
+func (javaDetector) Name() string       { return "Java/Kotlin" }
+
+func (javaDetector) RuleKinds() map[RuleRole][]string {
+	return map[RuleRole][]string{
+		RoleTest:    {"java_test", "kt_jvm_test"},
+		RoleLibrary: {"java_library", "kt_jvm_library"},
+		RoleBinary:  {"java_binary", "kt_jvm_binary"},
+	}
+}
+
+func (javaDetector) Owns(filename string) bool {
+	return strings.HasSuffix(filename, ".java") || strings.HasSuffix(filename, ".kt")
+}
+
+func (javaDetector) EnterDir(parent DirState, dir *Dir) DirState {
+	j, _ := parent.(jvmState)
+	for _, entry := range dir.Entries {
+		if tool, ok := jvmBuildFiles[entry.Name()]; ok && !entry.IsDir() {
+			j.moduleRoot = filepath.ToSlash(dir.RelPath)
+			j.buildTool = tool
+			break
+		}
+	}
+	return j
+}
+
+// ClassifyFile treats test classes in a test source set (src/test,
+// src/integrationTest, ...) as tests and its other classes as test helpers.
+// Classes in other source sets, like src/main, are sources whatever their
+// name. Outside the standard layout, test classes are recognized by name.
+func (javaDetector) ClassifyFile(dir *Dir, filename string) (FileKind, ExclusionReason) {
+	isTest := isJVMTestName(filename)
+	if _, set, ok := jvmSourceSet(filepath.ToSlash(dir.RelPath)); ok {
+		switch {
+		case !strings.Contains(strings.ToLower(set), "test"):
+			return FileSource, ""
+		case isTest:
+			return FileTest, ""
+		default:
+			return FileSupport, ""
+		}
+	}
+	if isTest {
+		return FileTest, ""
+	}
+	return FileSource, ""
+}
+
+func (javaDetector) InitPackage(dir *Dir, pkg *Package) {}
+
+// Finish groups the source sets of a module under the module root: the
+// directory holding src/main/java and friends, or else the nearest pom.xml
+// or build.gradle. Directories outside any module are packages on their own.
+func (javaDetector) Finish(dirs []*DirResult) ([]*Package, []*ExcludedPackage) {
+	rootOf := func(dr *DirResult) string {
+		if root, _, ok := jvmSourceSet(filepath.ToSlash(dr.RelPath)); ok {
+			return root
+		}
+		return dr.State.(jvmState).moduleRoot
+	}
+	modules := make(map[string]string)
+	for _, dr := range dirs {
+		if j := dr.State.(jvmState); j.moduleRoot != "" {
+			modules[j.moduleRoot] = j.buildTool
+		}
+	}
+	return mergeUnderRoots(LangJava, dirs, rootOf, func(dr *DirResult, pkg *Package) {
+		pkg.BuildTool = modules[filepath.ToSlash(pkg.RelPath)]
+	}), nil
+}
@@ -0,0 +1,24 @@
+package scanner
+
+import "testing"
+
+func TestJavaClassifyFile(t *testing.T) {
+	tests := []struct {
+		dir, file string
+		want      FileKind
+	}{
+		{"src/main/java/com/example", "TestDataFactory.java", FileSource},
+		{"app/src/main/kotlin/com/example", "UserServiceTest.kt", FileSource},
+		{"src/test/java/com/example", "UserServiceTest.java", FileTest},
+		{"src/integrationTest/java/com/example", "TestBase.java", FileTest},
+		{"src/test/java/com/example", "Fixtures.java", FileSupport},
+		{"java/com/example", "UserServiceTest.java", FileTest},
+		{"java/com/example", "UserService.java", FileSource},
+	}
+	for _, tt := range tests {
+		got, reason := javaDetector{}.ClassifyFile(&Dir{RelPath: tt.dir}, tt.file)
+		if got != tt.want || reason != "" {
+			t.Errorf("ClassifyFile(%s/%s) = %v, %q; want %v", tt.dir, tt.file, got, reason, tt.want)
+		}
+	}
+}
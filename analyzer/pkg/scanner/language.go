@@ -0,0 +1,307 @@
+package scanner
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LanguageDetector adds a language to the scanner. It decides which files
+// belong to the language and how they are classified, names the rule kinds
+// that build it, and turns per-directory results into packages.
+type LanguageDetector interface {
+	// Language identifies the language in results and config files
+	Language() Language
+	// Name is the display name, e.g. "Python"
+	Name() string
+	// RuleKinds names the built-in rule kinds for each role
+	RuleKinds() map[RuleRole][]string
+
+	// Owns reports whether a file belongs to the language
+	Owns(filename string) bool
+	// EnterDir returns a directory's state given the state its parent passed
+	// down, which is nil for the repository root
+	EnterDir(parent DirState, dir *Dir) DirState
+	// ClassifyFile classifies one of the language's files, or returns why
+	// the language's toolchain would leave it out
+	ClassifyFile(dir *Dir, filename string) (FileKind, ExclusionReason)
+	// InitPackage fills in the language-specific fields of a directory's
+	// package when its first file is added
+	InitPackage(dir *Dir, pkg *Package)
+	// Finish turns the per-directory results into the language's packages,
+	// dropping, merging or excluding them as the language requires
+	Finish(dirs []*DirResult) ([]*Package, []*ExcludedPackage)
+}
+
+// DirState is the context a directory inherits from its parents for one
+// language, e.g. the enclosing Go module
+type DirState interface {
+	// Key identifies the state for scan cache fingerprints
+	Key() string
+	// Child returns the state passed down to a subdirectory
+	Child(name string) DirState
+}
+
+// Dir is a directory as seen by a LanguageDetector
+type Dir struct {
+	Path    string
+	RelPath string
+	// Entries is the directory listing, only set while it is being scanned
+	Entries []os.DirEntry
+	// State is the language's state for the directory, from EnterDir
+	State DirState
+
+	// fsys is the scanned file system, which is not necessarily the disk
+	// under Path
+	fsys fs.FS
+}
+
+// ReadFile reads one of the directory's files from the scanned file system
+func (d *Dir) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(d.fsys, d.file(name))
+}
+
+// Open opens one of the directory's files from the scanned file system
+func (d *Dir) Open(name string) (fs.File, error) {
+	return d.fsys.Open(d.file(name))
+}
+
+// file returns the path of one of the directory's files in the file system
+func (d *Dir) file(name string) string {
+	return path.Join(filepath.ToSlash(d.RelPath), name)
+}
+
+// FileKind is how a language classifies one of its files
+type FileKind int
+
+const (
+	FileSource FileKind = iota
+	FileTest
+	// FileSourceWithTests is a source file that also holds tests, like a
+	// Rust module with #[cfg(test)]
+	FileSourceWithTests
+	// FileSupport is neither a source nor a test, like __init__.py
+	FileSupport
+)
+
+// DirResult is a scanned directory's result for one language
+type DirResult struct {
+	Dir
+	HasBuild bool
+	// Package is nil when the directory has none of the language's files
+	Package *Package
+	// Targets counts the language's BUILD targets by role, and TargetList
+	// holds every rule of the directory's BUILD file, including rules of
+	// other languages and language-independent ones like genrule
+	Targets    map[RuleRole]int
+	TargetList []*Target
+}
+
+// LanguageResult holds a language's packages and totals
+type LanguageResult struct {
+	Language Language `json:"language"`
+	Name     string   `json:"name"`
+	// TestRuleKinds are the built-in test rule kinds, e.g. go_test
+	TestRuleKinds []string   `json:"testRuleKinds"`
+	Packages      []*Package `json:"packages"`
+
+	TotalSourceFiles    int `json:"totalSourceFiles"`
+	TotalTestFiles      int `json:"totalTestFiles"`
+	TotalTestRules      int `json:"totalTestRules"`
+	TotalGeneratedFiles int `json:"totalGeneratedFiles"`
+}
+
+// defaultLanguages returns the built-in language detectors
+func (s *Scanner) defaultLanguages() []LanguageDetector {
+	return []LanguageDetector{
+		&goDetector{buildTags: s.goBuildTags},
+		pythonDetector{},
+		rustDetector{},
+		javaDetector{},
+		typescriptDetector{},
+		cppDetector{},
+	}
+}
+
+// detectorFor returns the detector of the first language owning a file
+func (s *Scanner) detectorFor(filename string) LanguageDetector {
+	for _, d := range s.languages {
+		if d.Owns(filename) {
+			return d
+		}
+	}
+	return nil
+}
+
+// addFile classifies a file into the directory's package for its language
+func (s *Scanner) addFile(dp *dirPackages, entries []os.DirEntry, filename string) {
+	d := s.detectorFor(filename)
+	if d == nil {
+		return
+	}
+	lang := d.Language()
+	dir := &Dir{Path: dp.path, RelPath: dp.relPath, Entries: entries, State: dp.states[lang], fsys: s.fsys}
+	kind, reason := d.ClassifyFile(dir, filename)
+	if reason != "" {
+		dp.exclude(lang, reason)
+		return
+	}
+
+	// Generated sources only count towards their package's generated files
+	generated := kind == FileSource && s.generatedFile(dp, filename)
+
+	pkg := dp.pkgs[lang]
+	if pkg == nil {
+		pkg = dp.newPackage(lang)
+		d.InitPackage(dir, pkg)
+		if dp.pkgs == nil {
+			dp.pkgs = make(map[Language]*Package)
+		}
+		dp.pkgs[lang] = pkg
+	}
+	if generated {
+		pkg.GeneratedFileCount++
+		dp.addGeneratedFile(lang, filename)
+		return
+	}
+	dp.addDirFile(lang, kind, filename)
+	switch kind {
+	case FileSource:
+		pkg.SourceFileCount++
+	case FileTest:
+		pkg.HasTestFiles = true
+		pkg.TestFileCount++
+	case FileSourceWithTests:
+		pkg.SourceFileCount++
+		pkg.HasTestFiles = true
+		pkg.TestFileCount++
+	case FileSupport:
+		pkg.SupportFileCount++
+	}
+}
+
+// finishLanguage assigns BUILD files and targets to a language's directory
+// packages and lets its detector turn them into the final packages
+func (s *Scanner) finishLanguage(d LanguageDetector, dirs []*dirPackages) (*LanguageResult, []*ExcludedPackage) {
+	lang := d.Language()
+	results := make([]*DirResult, 0, len(dirs))
+	for _, dp := range dirs {
+		dr := &DirResult{
+			Dir:      Dir{Path: dp.path, RelPath: dp.relPath, State: dp.states[lang], fsys: s.fsys},
+			HasBuild: dp.hasBuild,
+			Package:  dp.pkgs[lang],
+			Targets: map[RuleRole]int{
+				RoleTest:    dp.targets.count(lang, RoleTest),
+				RoleLibrary: dp.targets.count(lang, RoleLibrary),
+				RoleBinary:  dp.targets.count(lang, RoleBinary),
+			},
+			TargetList: dp.targetList,
+		}
+		if pkg := dr.Package; pkg != nil {
+			pkg.Targets = dr.TargetList
+			pkg.HasBuildFile = dp.hasBuild
+			pkg.TestTargetCount = dr.Targets[RoleTest]
+			pkg.LibraryTargets = dr.Targets[RoleLibrary]
+			pkg.BinaryTargets = dr.Targets[RoleBinary]
+		}
+		results = append(results, dr)
+	}
+
+	pkgs, excluded := d.Finish(results)
+	lr := &LanguageResult{
+		Language:      lang,
+		Name:          d.Name(),
+		TestRuleKinds: d.RuleKinds()[RoleTest],
+		Packages:      make([]*Package, 0, len(pkgs)),
+	}
+	for _, pkg := range pkgs {
+		lr.TotalSourceFiles += pkg.SourceFileCount
+		lr.TotalTestFiles += pkg.TestFileCount
+		lr.TotalTestRules += pkg.TestTargetCount
+		lr.TotalGeneratedFiles += pkg.GeneratedFileCount
+		lr.Packages = append(lr.Packages, pkg)
+	}
+	return lr, excluded
+}
+
+// mergeUnderRoots merges the packages of all directories below a common
+// root, like a Cargo crate or a Maven module, into one package at the root.
+// rootOf returns a directory's slash-separated root, or "" for directories
+// outside any root, which stay packages on their own; init fills in a merged
+// package from the first directory contributing to it. A merged package has
+// a BUILD file if its root has one or any of its directories declares
+// targets of the language.
+func mergeUnderRoots(lang Language, dirs []*DirResult, rootOf func(*DirResult) string, init func(*DirResult, *Package)) []*Package {
+	paths := make(map[string]string, len(dirs))
+	for _, dr := range dirs {
+		paths[filepath.ToSlash(dr.RelPath)] = dr.Path
+	}
+
+	merged := make(map[string]*Package)
+	var pkgs []*Package
+	for _, dr := range dirs {
+		if dr.Package == nil {
+			continue
+		}
+		root := rootOf(dr)
+		if root == "" {
+			pkgs = append(pkgs, dr.Package)
+			continue
+		}
+		pkg, ok := merged[root]
+		if !ok {
+			pkg = &Package{
+				Path:     paths[root],
+				RelPath:  filepath.FromSlash(root),
+				Language: lang,
+			}
+			init(dr, pkg)
+			merged[root] = pkg
+			pkgs = append(pkgs, pkg)
+		}
+		pkg.SourceFileCount += dr.Package.SourceFileCount
+		pkg.TestFileCount += dr.Package.TestFileCount
+		pkg.SupportFileCount += dr.Package.SupportFileCount
+		pkg.GeneratedFileCount += dr.Package.GeneratedFileCount
+		pkg.listedGeneratedFiles += dr.Package.listedGeneratedFiles
+		pkg.HasTestFiles = pkg.HasTestFiles || dr.Package.HasTestFiles
+
+		// Uncovered files are relative to the merged package
+		prefix := ""
+		if rel := filepath.ToSlash(dr.RelPath); rel != root {
+			prefix = strings.TrimPrefix(rel, root+"/")
+			if root == "." {
+				prefix = rel
+			}
+			prefix += "/"
+		}
+		for _, name := range dr.Package.UncoveredTestFiles {
+			pkg.UncoveredTestFiles = append(pkg.UncoveredTestFiles, prefix+name)
+		}
+		for _, name := range dr.Package.UncoveredSourceFiles {
+			pkg.UncoveredSourceFiles = append(pkg.UncoveredSourceFiles, prefix+name)
+		}
+	}
+
+	for _, dr := range dirs {
+		root := rootOf(dr)
+		pkg, ok := merged[root]
+		if !ok {
+			continue
+		}
+		if dr.HasBuild && filepath.ToSlash(dr.RelPath) == root {
+			pkg.HasBuildFile = true
+		}
+		tests, libs, bins := dr.Targets[RoleTest], dr.Targets[RoleLibrary], dr.Targets[RoleBinary]
+		if tests+libs+bins > 0 {
+			pkg.HasBuildFile = true
+		}
+		pkg.TestTargetCount += tests
+		pkg.LibraryTargets += libs
+		pkg.BinaryTargets += bins
+		pkg.Targets = append(pkg.Targets, dr.TargetList...)
+	}
+	return pkgs
+}
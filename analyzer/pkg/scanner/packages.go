@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Ownership is how a package's directory relates to the Bazel package tree
+type Ownership string
+
+const (
+	// OwnedByBuild packages have targets of their own
+	OwnedByBuild Ownership = "build"
+	// OwnedByAncestor packages have no BUILD file, but the rules of the
+	// nearest ancestor Bazel package list some of their files, e.g. through
+	// glob(["**/*.go"]) or "sub/file.py"
+	OwnedByAncestor Ownership = "ancestor"
+	// Unowned packages have no files built by any Bazel package
+	Unowned Ownership = "unowned"
+)
+
+// BazelPackage is a directory with a BUILD file, as a node of the Bazel
+// package tree
+type BazelPackage struct {
+	Label   string `json:"label"`
+	RelPath string `json:"relPath"`
+	// Parent is the label of the enclosing Bazel package, if any
+	Parent string `json:"parent,omitempty"`
+	// Workspace is the relPath of the Bazel workspace the package belongs to
+	Workspace string `json:"workspace"`
+	// SourceDirs are the directories below the package without a BUILD file
+	// of their own that hold source or test files
+	SourceDirs []string `json:"sourceDirs"`
+}
+
+// packageLabel returns the label of a Bazel package given its path within
+// its workspace, e.g. "//foo/bar" or "//" for the workspace root
+func packageLabel(relPath string) string {
+	pkg := filepath.ToSlash(relPath)
+	if pkg == "." {
+		pkg = ""
+	}
+	return "//" + pkg
+}
+
+// indexDirs maps slash-separated repo-relative paths to their directories
+func indexDirs(dirs []*dirPackages) map[string]*dirPackages {
+	byRel := make(map[string]*dirPackages, len(dirs))
+	for _, dp := range dirs {
+		byRel[filepath.ToSlash(dp.relPath)] = dp
+	}
+	return byRel
+}
+
+// owningPackage returns the directory of the Bazel package containing
+// relSlash and relSlash's path within it, or nil if no BUILD file of
+// relSlash's workspace encloses it
+func owningPackage(byRel map[string]*dirPackages, relSlash string) (*dirPackages, string) {
+	root := "."
+	if w := workspaceOf(byRel, relSlash); w != nil {
+		root = w.relSlash
+	}
+	for p := relSlash; ; p = path.Dir(p) {
+		if dp := byRel[p]; dp != nil && dp.hasBuild {
+			switch {
+			case p == relSlash:
+				return dp, ""
+			case p == ".":
+				return dp, relSlash
+			default:
+				return dp, strings.TrimPrefix(relSlash, p+"/")
+			}
+		}
+		if p == root || p == "." {
+			return nil, ""
+		}
+	}
+}
+
+// buildPackageTree returns the Bazel packages of the scanned directories,
+// sorted by path, with the source directories each one owns
+func buildPackageTree(dirs []*dirPackages, byRel map[string]*dirPackages) []*BazelPackage {
+	nodes := make(map[*dirPackages]*BazelPackage)
+	tree := make([]*BazelPackage, 0)
+	for _, dp := range dirs {
+		if !dp.hasBuild {
+			continue
+		}
+		node := &BazelPackage{
+			Label:      dp.workspace.packageLabel(dp.relPath),
+			RelPath:    dp.relPath,
+			Workspace:  dp.workspace.ws.RelPath,
+			SourceDirs: make([]string, 0),
+		}
+		if relSlash := filepath.ToSlash(dp.relPath); relSlash != dp.workspace.relSlash {
+			if parent, _ := owningPackage(byRel, path.Dir(relSlash)); parent != nil {
+				node.Parent = parent.workspace.packageLabel(parent.relPath)
+			}
+		}
+		nodes[dp] = node
+		tree = append(tree, node)
+	}
+
+	for _, dp := range dirs {
+		if dp.hasBuild || len(dp.files) == 0 {
+			continue
+		}
+		if owner, _ := owningPackage(byRel, filepath.ToSlash(dp.relPath)); owner != nil {
+			nodes[owner].SourceDirs = append(nodes[owner].SourceDirs, dp.relPath)
+		}
+	}
+
+	for _, node := range tree {
+		sort.Strings(node.SourceDirs)
+	}
+	sort.Slice(tree, func(i, j int) bool {
+		return tree[i].RelPath < tree[j].RelPath
+	})
+	return tree
+}
+
+// applyOwnership maps each language package to the Bazel package owning its
+// directory and records how the package is owned
+func applyOwnership(langs []*LanguageResult, byRel map[string]*dirPackages) {
+	for _, lr := range langs {
+		for _, pkg := range lr.Packages {
+			owner, _ := owningPackage(byRel, filepath.ToSlash(pkg.RelPath))
+			if owner != nil {
+				pkg.BazelPackage = owner.workspace.packageLabel(owner.relPath)
+			}
+			switch {
+			case pkg.HasBuildFile:
+				pkg.Ownership = OwnedByBuild
+			case owner != nil && listsAnyFile(pkg):
+				pkg.Ownership = OwnedByAncestor
+			default:
+				pkg.Ownership = Unowned
+			}
+		}
+	}
+}
+
+// listsAnyFile reports whether some target lists one of the package's
+// source, test or generated files
+func listsAnyFile(pkg *Package) bool {
+	return len(pkg.UncoveredSourceFiles) < pkg.SourceFileCount ||
+		len(pkg.UncoveredTestFiles) < pkg.TestFileCount ||
+		pkg.listedGeneratedFiles > 0
+}
@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestOwnershipOfGeneratedOnlyPackages(t *testing.T) {
+	tests := []struct {
+		name  string
+		build string
+		want  Ownership
+	}{
+		{"listed by an ancestor's glob", `py_library(name = "lib", srcs = glob(["**/*.py"]))`, OwnedByAncestor},
+		{"listed by an ancestor's srcs", `py_library(name = "lib", srcs = ["gen/api_pb2.py"])`, OwnedByAncestor},
+		{"not listed", `py_library(name = "lib", srcs = glob(["*.py"]))`, Unowned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scanFS(t, fstest.MapFS{
+				"BUILD.bazel":    file(tt.build + "\n"),
+				"lib.py":         file("x = 1\n"),
+				"gen/api_pb2.py": file("# Generated by the protocol buffer compiler.  DO NOT EDIT!\n"),
+			})
+			for _, pkg := range result.Packages(LangPython) {
+				if pkg.RelPath != "gen" {
+					continue
+				}
+				if pkg.GeneratedFileCount != 1 || pkg.SourceFileCount != 0 {
+					t.Fatalf("gen has %d generated and %d source files, want 1 and 0", pkg.GeneratedFileCount, pkg.SourceFileCount)
+				}
+				if pkg.Ownership != tt.want {
+					t.Errorf("gen ownership = %q, want %q", pkg.Ownership, tt.want)
+				}
+				return
+			}
+			t.Fatal("no Python package at gen")
+		})
+	}
+}
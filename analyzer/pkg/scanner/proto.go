@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// protoBindingKinds maps the rule kinds that generate code from a
+// proto_library to the language they generate
+var protoBindingKinds = map[string]Language{
+	"go_proto_library":        LangGo,
+	"go_grpc_library":         LangGo,
+	"py_proto_library":        LangPython,
+	"py_grpc_library":         LangPython,
+	"rust_prost_library":      LangRust,
+	"rust_tonic_library":      LangRust,
+	"java_proto_library":      LangJava,
+	"java_lite_proto_library": LangJava,
+	"java_grpc_library":       LangJava,
+	"kt_jvm_proto_library":    LangJava,
+	"ts_proto_library":        LangTypeScript,
+	"cc_proto_library":        LangCpp,
+	"cc_grpc_library":         LangCpp,
+}
+
+// protoGeneratedSuffixes recognize protoc output checked into the tree
+var protoGeneratedSuffixes = []struct {
+	suffix string
+	lang   Language
+}{
+	{".pb.go", LangGo},
+	{"_pb2.py", LangPython},
+	{"_pb2.pyi", LangPython},
+	{"_pb2_grpc.py", LangPython},
+	{".pb.cc", LangCpp},
+	{".pb.h", LangCpp},
+	{"_pb.d.ts", LangTypeScript},
+	{"_pb.ts", LangTypeScript},
+	{"_pb.js", LangTypeScript},
+}
+
+var protoPackageStmt = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+
+// ProtoPackage is a directory of .proto files and the Bazel rules that
+// generate code from it
+type ProtoPackage struct {
+	Path    string `json:"path"`
+	RelPath string `json:"relPath"`
+	// ProtoPackage is the package statement of the directory's first .proto
+	ProtoPackage    string `json:"protoPackage,omitempty"`
+	ProtoFileCount  int    `json:"protoFileCount"`
+	HasProtoLibrary bool   `json:"hasProtoLibrary"`
+	// Bindings are the languages with a *_proto_library or *_grpc_library
+	// rule in the package
+	Bindings []Language `json:"bindings"`
+	// GeneratedFiles counts checked-in protoc output next to the .proto
+	// files, per language
+	GeneratedFiles map[Language]int `json:"generatedFiles,omitempty"`
+	// GeneratedOutsideBazel are the languages with checked-in generated code
+	// but no Bazel binding, i.e. generated by hand or by another build
+	GeneratedOutsideBazel []Language `json:"generatedOutsideBazel"`
+}
+
+// protoDir is what a directory contributes to proto coverage
+type protoDir struct {
+	Files        int               `json:"files,omitempty"`
+	ProtoPackage string            `json:"protoPackage,omitempty"`
+	HasLibrary   bool              `json:"hasLibrary,omitempty"`
+	Bindings     map[Language]bool `json:"bindings,omitempty"`
+	Generated    map[Language]int  `json:"generated,omitempty"`
+}
+
+// protoDir returns the directory's proto info, creating it if needed
+func (dp *dirPackages) protoDir() *protoDir {
+	if dp.proto == nil {
+		dp.proto = &protoDir{}
+	}
+	return dp.proto
+}
+
+// addProtoFile records a .proto file or checked-in protoc output
+func (dp *dirPackages) addProtoFile(fsys fs.FS, filename string) {
+	if strings.HasSuffix(filename, ".proto") {
+		pd := dp.protoDir()
+		pd.Files++
+		if pd.ProtoPackage == "" {
+			if data, err := fs.ReadFile(fsys, dp.file(filename)); err == nil {
+				if m := protoPackageStmt.FindSubmatch(data); m != nil {
+					pd.ProtoPackage = string(m[1])
+				}
+			}
+		}
+		return
+	}
+	for _, gen := range protoGeneratedSuffixes {
+		if strings.HasSuffix(filename, gen.suffix) {
+			pd := dp.protoDir()
+			if pd.Generated == nil {
+				pd.Generated = make(map[Language]int)
+			}
+			pd.Generated[gen.lang]++
+			return
+		}
+	}
+}
+
+// addProtoRules records the proto_library and binding rules of a BUILD file
+func (dp *dirPackages) addProtoRules(bf *BuildFile) {
+	for _, rule := range bf.Rules {
+		if rule.Kind == "proto_library" {
+			dp.protoDir().HasLibrary = true
+			continue
+		}
+		if lang, ok := protoBindingKinds[rule.Kind]; ok {
+			pd := dp.protoDir()
+			if pd.Bindings == nil {
+				pd.Bindings = make(map[Language]bool)
+			}
+			pd.Bindings[lang] = true
+		}
+	}
+}
+
+// protoPackage returns the directory's proto package, or nil if it has no
+// .proto files
+func (dp *dirPackages) protoPackage() *ProtoPackage {
+	pd := dp.proto
+	if pd == nil || pd.Files == 0 {
+		return nil
+	}
+	pkg := &ProtoPackage{
+		Path:                  dp.path,
+		RelPath:               dp.relPath,
+		ProtoPackage:          pd.ProtoPackage,
+		ProtoFileCount:        pd.Files,
+		HasProtoLibrary:       pd.HasLibrary,
+		Bindings:              make([]Language, 0, len(pd.Bindings)),
+		GeneratedFiles:        pd.Generated,
+		GeneratedOutsideBazel: make([]Language, 0),
+	}
+	for lang := range pd.Bindings {
+		pkg.Bindings = append(pkg.Bindings, lang)
+	}
+	for lang := range pd.Generated {
+		if !pd.Bindings[lang] {
+			pkg.GeneratedOutsideBazel = append(pkg.GeneratedOutsideBazel, lang)
+		}
+	}
+	sort.Slice(pkg.Bindings, func(i, j int) bool { return pkg.Bindings[i] < pkg.Bindings[j] })
+	sort.Slice(pkg.GeneratedOutsideBazel, func(i, j int) bool {
+		return pkg.GeneratedOutsideBazel[i] < pkg.GeneratedOutsideBazel[j]
+	})
+	return pkg
+}
@@ -0,0 +1,396 @@
+package scanner
+
+import (
+	"bufio"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultPythonTestPatterns are used when no pytest python_files is configured
+var defaultPythonTestPatterns = []string{"test_*.py", "*_test.py", "*_tests.py"}
+
+// pythonSupportFiles are Python files that are neither library sources nor
+// tests: package markers, pytest fixtures and packaging scripts
+var pythonSupportFiles = map[string]bool{
+	"__init__.py": true,
+	"conftest.py": true,
+	"setup.py":    true,
+	"noxfile.py":  true,
+}
+
+// pythonProjectFiles mark the root of a Python project
+var pythonProjectFiles = []string{"pyproject.toml", "setup.cfg", "setup.py"}
+
+// pytestConfig is the part of a pytest configuration that decides which
+// files are tests
+type pytestConfig struct {
+	dir         string   // slash-separated repo-relative directory of the config file
+	testPaths   []string // slash-separated repo-relative test directories
+	pythonFiles []string // basename patterns
+}
+
+// pythonState is the Python project context a directory inherits from its
+// parents
+type pythonState struct {
+	projectRoot string // slash-separated repo-relative project root, "" outside any project
+	pytest      *pytestConfig
+	// inTests is set inside tests/ and test/ trees, where non-test modules
+	// are test helpers
+	inTests bool
+}
+
+// Key identifies the state for cache fingerprints
+func (p pythonState) Key() string {
+	var b strings.Builder
+	b.WriteString(p.projectRoot)
+	if p.pytest != nil {
+		b.WriteString(";" + p.pytest.dir + ";" + strings.Join(p.pytest.testPaths, ",") + ";" + strings.Join(p.pytest.pythonFiles, ","))
+	}
+	if p.inTests {
+		b.WriteString(";tests")
+	}
+	return b.String()
+}
+
+// enterDir applies a directory's project and pytest config files to the
+// state inherited from its parent
+func (p pythonState) enterDir(dir *Dir) pythonState {
+	relSlash := filepath.ToSlash(dir.RelPath)
+	names := make(map[string]bool, len(dir.Entries))
+	for _, entry := range dir.Entries {
+		if !entry.IsDir() {
+			names[entry.Name()] = true
+		}
+	}
+
+	for _, name := range pythonProjectFiles {
+		if names[name] {
+			p.projectRoot = relSlash
+			break
+		}
+	}
+
+	// pytest uses the first of these files that holds a pytest section
+	for _, name := range []string{"pytest.ini", "pyproject.toml", "tox.ini", "setup.cfg"} {
+		if !names[name] {
+			continue
+		}
+		if cfg := loadPytestConfig(dir, name, relSlash); cfg != nil {
+			p.pytest = cfg
+			break
+		}
+	}
+	return p
+}
+
+// Child returns the state for a subdirectory
+func (p pythonState) Child(name string) DirState {
+	if name == "tests" || name == "test" {
+		p.inTests = true
+	}
+	return p
+}
+
+// classify decides whether a .py file in the directory is a source, a test
+// or a support file
+func (p pythonState) classify(relSlash, filename string) FileKind {
+	if pythonSupportFiles[filename] {
+		return FileSupport
+	}
+
+	patterns := defaultPythonTestPatterns
+	if p.pytest != nil && len(p.pytest.pythonFiles) > 0 {
+		patterns = p.pytest.pythonFiles
+	}
+	isTest := false
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, filename); ok {
+			isTest = true
+			break
+		}
+	}
+	// With testpaths configured, pytest only collects tests below them
+	if isTest && p.pytest != nil && len(p.pytest.testPaths) > 0 {
+		isTest = false
+		for _, tp := range p.pytest.testPaths {
+			if tp == "." || relSlash == tp || strings.HasPrefix(relSlash, tp+"/") {
+				isTest = true
+				break
+			}
+		}
+	}
+
+	switch {
+	case isTest:
+		return FileTest
+	case p.inTests:
+		return FileSupport
+	}
+	return FileSource
+}
+
+// importPath returns the dotted module path of a regular package directory
+// relative to its project's source root, or "" if it cannot be imported
+func (p pythonState) importPath(relSlash string) string {
+	if p.projectRoot == "" {
+		return ""
+	}
+	rel := relSlash
+	if p.projectRoot != "." {
+		if !strings.HasPrefix(relSlash, p.projectRoot+"/") {
+			return ""
+		}
+		rel = strings.TrimPrefix(relSlash, p.projectRoot+"/")
+	} else if relSlash == "." {
+		return ""
+	}
+	rel = strings.TrimPrefix(rel, "src/") // src layout
+	parts := strings.Split(rel, "/")
+	for _, part := range parts {
+		if !pythonIdentifier.MatchString(part) {
+			return ""
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+var pythonIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pythonDetector classifies Python files by project structure and pytest
+// config
+type pythonDetector struct{}
+
+func (pythonDetector) Language() Language { return LangPython }
+func (pythonDetector) Name() string       { return "Python" }
+
+func (pythonDetector) RuleKinds() map[RuleRole][]string {
+	return map[RuleRole][]string{
+		RoleTest:    {"py_test"},
+		RoleLibrary: {"py_library"},
+		RoleBinary:  {"py_binary"},
+	}
+}
+
+func (pythonDetector) Owns(filename string) bool {
+	return strings.HasSuffix(filename, ".py")
+}
+
+func (pythonDetector) EnterDir(parent DirState, dir *Dir) DirState {
+	p, _ := parent.(pythonState)
+	return p.enterDir(dir)
+}
+
+// ClassifyFile uses the pytest config's test patterns, defaulting to
+// test_*.py, *_test.py and *_tests.py
+func (pythonDetector) ClassifyFile(dir *Dir, filename string) (FileKind, ExclusionReason) {
+	return dir.State.(pythonState).classify(filepath.ToSlash(dir.RelPath), filename), ""
+}
+
+func (pythonDetector) InitPackage(dir *Dir, pkg *Package) {
+	p := dir.State.(pythonState)
+	pkg.ProjectRoot = p.projectRoot
+	for _, entry := range dir.Entries {
+		if entry.Name() == "__init__.py" && !entry.IsDir() {
+			pkg.ImportPath = p.importPath(filepath.ToSlash(dir.RelPath))
+			break
+		}
+	}
+}
+
+// Finish drops directories with only __init__.py, conftest.py and the like,
+// which are not Python packages in their own right. Directories of
+// generated modules are kept, to be reported as generated-only.
+func (pythonDetector) Finish(dirs []*DirResult) ([]*Package, []*ExcludedPackage) {
+	var pkgs []*Package
+	for _, dr := range dirs {
+		if dr.Package != nil && (dr.Package.SourceFileCount > 0 || dr.Package.TestFileCount > 0 || dr.Package.GeneratedFileCount > 0) {
+			pkgs = append(pkgs, dr.Package)
+		}
+	}
+	return pkgs, nil
+}
+
+// loadPytestConfig reads testpaths and python_files from a pytest.ini,
+// tox.ini, setup.cfg or pyproject.toml. It returns nil if the file has no
+// pytest section.
+func loadPytestConfig(dir *Dir, filename, relSlash string) *pytestConfig {
+	var (
+		values map[string][]string
+		ok     bool
+	)
+	switch filename {
+	case "pyproject.toml":
+		values, ok = readTOMLSection(dir, filename, "tool.pytest.ini_options")
+	case "setup.cfg":
+		values, ok = readINISection(dir, filename, "tool:pytest")
+	default:
+		values, ok = readINISection(dir, filename, "pytest")
+	}
+	if !ok {
+		return nil
+	}
+
+	cfg := &pytestConfig{dir: relSlash, pythonFiles: values["python_files"]}
+	for _, tp := range values["testpaths"] {
+		cfg.testPaths = append(cfg.testPaths, path.Join(relSlash, filepath.ToSlash(tp)))
+	}
+	return cfg
+}
+
+// readINISection returns the whitespace-separated values of each key in an
+// INI section, following indented continuation lines. Comments start with #
+// or ; at the start of a line or after whitespace.
+func readINISection(dir *Dir, filename, section string) (map[string][]string, bool) {
+	file, err := dir.Open(filename)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	values := make(map[string][]string)
+	found, inSection := false, false
+	key := ""
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		raw := stripINIComment(sc.Text())
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			found = found || inSection
+			key = ""
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if raw[0] == ' ' || raw[0] == '\t' {
+			if key != "" {
+				values[key] = append(values[key], strings.Fields(line)...)
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			k, v, ok = strings.Cut(line, ":")
+		}
+		if !ok {
+			key = ""
+			continue
+		}
+		key = strings.TrimSpace(k)
+		values[key] = strings.Fields(v)
+	}
+	return values, found
+}
+
+// stripINIComment removes a comment from an INI line
+func stripINIComment(line string) string {
+	for i, r := range line {
+		if (r == '#' || r == ';') && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+var tomlString = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+
+// readTOMLSection returns the string or string-array values of each key in a
+// TOML table. Only the simple forms used in pytest configs and Cargo
+// manifests are understood. A sub-table like [workspace.dependencies]
+// defines its parent table too, but its keys are not the parent's.
+func readTOMLSection(dir *Dir, filename, table string) (map[string][]string, bool) {
+	file, err := dir.Open(filename)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	values := make(map[string][]string)
+	found, inTable := false, false
+	key := "" // key of an array spanning several lines
+	var buf strings.Builder
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(stripTOMLComment(sc.Text()))
+		if key != "" {
+			buf.WriteString(" " + line)
+			if tomlArrayClosed(line) {
+				values[key] = tomlStrings(buf.String())
+				key = ""
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name := strings.Trim(line, "[] ")
+			inTable = name == table
+			found = found || inTable || strings.HasPrefix(name, table+".")
+			continue
+		}
+		if !inTable {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch {
+		case strings.HasPrefix(v, "[") && !tomlArrayClosed(v):
+			key = k
+			buf.Reset()
+			buf.WriteString(v)
+		case strings.HasPrefix(v, "["):
+			values[k] = tomlStrings(v)
+		default:
+			// A single string holds whitespace-separated values, as in INI files
+			values[k] = strings.Fields(strings.Join(tomlStrings(v), " "))
+		}
+	}
+	return values, found
+}
+
+// stripTOMLComment removes a comment from a TOML line, leaving any # inside
+// strings alone
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tomlArrayClosed reports whether a line closes an array, with a ] outside
+// of strings
+func tomlArrayClosed(line string) bool {
+	return strings.Contains(tomlString.ReplaceAllString(line, ""), "]")
+}
+
+func tomlStrings(s string) []string {
+	var out []string
+	for _, m := range tomlString.FindAllStringSubmatch(s, -1) {
+		if m[1] != "" {
+			out = append(out, m[1])
+		} else {
+			out = append(out, m[2])
+		}
+	}
+	return out
+}
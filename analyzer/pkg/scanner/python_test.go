@@ -0,0 +1,179 @@
+package scanner
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+)
+
+// configDir is a directory holding one config file
+func configDir(name, content string) *Dir {
+	return &Dir{RelPath: ".", fsys: fstest.MapFS{name: {Data: []byte(content)}}}
+}
+
+func TestReadINISection(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		section   string
+		want      map[string][]string
+		wantFound bool
+	}{
+		{
+			name:      "single line",
+			content:   "[pytest]\ntestpaths = tests integration\n",
+			section:   "pytest",
+			want:      map[string][]string{"testpaths": {"tests", "integration"}},
+			wantFound: true,
+		},
+		{
+			name:      "continuation lines",
+			content:   "[pytest]\ntestpaths =\n    tests\n    integration\npython_files = check_*.py\n",
+			section:   "pytest",
+			want:      map[string][]string{"testpaths": {"tests", "integration"}, "python_files": {"check_*.py"}},
+			wantFound: true,
+		},
+		{
+			name:      "inline comments",
+			content:   "[pytest] ; pytest settings\ntestpaths = tests  # unit only\n    # integration\n    e2e ; slow\n",
+			section:   "pytest",
+			want:      map[string][]string{"testpaths": {"tests", "e2e"}},
+			wantFound: true,
+		},
+		{
+			name:      "colon separator",
+			content:   "[pytest]\ntestpaths: tests\n",
+			section:   "pytest",
+			want:      map[string][]string{"testpaths": {"tests"}},
+			wantFound: true,
+		},
+		{
+			name:      "tool:pytest is not pytest",
+			content:   "[tool:pytest]\ntestpaths = tests\n",
+			section:   "pytest",
+			want:      map[string][]string{},
+			wantFound: false,
+		},
+		{
+			name:      "pytest is not tool:pytest",
+			content:   "[pytest]\ntestpaths = tests\n[tool:pytest]\ntestpaths = src\n",
+			section:   "tool:pytest",
+			want:      map[string][]string{"testpaths": {"src"}},
+			wantFound: true,
+		},
+		{
+			name:      "other sections",
+			content:   "[flake8]\nmax-line-length = 100\n[pytest]\ntestpaths = tests\n[mypy]\nstrict = true\n",
+			section:   "pytest",
+			want:      map[string][]string{"testpaths": {"tests"}},
+			wantFound: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := readINISection(configDir("setup.cfg", tt.content), "setup.cfg", tt.section)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || found != tt.wantFound {
+				t.Errorf("readINISection = %v, %v; want %v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestReadTOMLSection(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		table     string
+		want      map[string][]string
+		wantFound bool
+	}{
+		{
+			name:      "strings and arrays",
+			content:   "[package]\nname = \"crate\"\nauthors = [\"a\", 'b']\n",
+			table:     "package",
+			want:      map[string][]string{"name": {"crate"}, "authors": {"a", "b"}},
+			wantFound: true,
+		},
+		{
+			name:      "multi-line array",
+			content:   "[workspace]\nmembers = [\n    \"crates/a\",\n    \"crates/b\",\n]\nresolver = \"2\"\n",
+			table:     "workspace",
+			want:      map[string][]string{"members": {"crates/a", "crates/b"}, "resolver": {"2"}},
+			wantFound: true,
+		},
+		{
+			name:      "inline comments",
+			content:   "[workspace] # the workspace\nmembers = [\n    \"a\", # first \"crate\"\n    # \"disabled\",\n    \"b#c\",\n] # done\n",
+			table:     "workspace",
+			want:      map[string][]string{"members": {"a", "b#c"}},
+			wantFound: true,
+		},
+		{
+			name:      "bracket inside a string",
+			content:   "[tool.pytest.ini_options]\npython_files = [\n  \"test_[a-z]*.py\",\n  \"check_*.py\",\n]\n",
+			table:     "tool.pytest.ini_options",
+			want:      map[string][]string{"python_files": {"test_[a-z]*.py", "check_*.py"}},
+			wantFound: true,
+		},
+		{
+			name:      "string of several values",
+			content:   "[tool.pytest.ini_options]\ntestpaths = \"tests integration\"\n",
+			table:     "tool.pytest.ini_options",
+			want:      map[string][]string{"testpaths": {"tests", "integration"}},
+			wantFound: true,
+		},
+		{
+			name:      "sub-table without its parent header",
+			content:   "[workspace.dependencies]\nserde = \"1.0\"\nmembers = [\"not-members\"]\n",
+			table:     "workspace",
+			want:      map[string][]string{},
+			wantFound: true,
+		},
+		{
+			name:      "table with a common prefix",
+			content:   "[workspaces]\nmembers = [\"a\"]\n",
+			table:     "workspace",
+			want:      map[string][]string{},
+			wantFound: false,
+		},
+		{
+			name:      "keys after the table",
+			content:   "[package]\nname = \"crate\"\n[dependencies]\nname = \"other\"\n",
+			table:     "package",
+			want:      map[string][]string{"name": {"crate"}},
+			wantFound: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := readTOMLSection(configDir("Cargo.toml", tt.content), "Cargo.toml", tt.table)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || found != tt.wantFound {
+				t.Errorf("readTOMLSection = %v, %v; want %v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestLoadPytestConfigSections(t *testing.T) {
+	tests := []struct {
+		file, content string
+		want          []string
+	}{
+		{"setup.cfg", "[tool:pytest]\ntestpaths = tests\n", []string{"tests"}},
+		{"setup.cfg", "[pytest]\ntestpaths = tests\n", nil},
+		{"tox.ini", "[pytest]\ntestpaths = tests\n", []string{"tests"}},
+		{"tox.ini", "[tool:pytest]\ntestpaths = tests\n", nil},
+		{"pytest.ini", "[pytest]\ntestpaths = tests\n", []string{"tests"}},
+		{"pyproject.toml", "[tool.pytest.ini_options]\ntestpaths = [\"tests\"]\n", []string{"tests"}},
+	}
+	for _, tt := range tests {
+		cfg := loadPytestConfig(configDir(tt.file, tt.content), tt.file, ".")
+		var got []string
+		if cfg != nil {
+			got = cfg.testPaths
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) || (cfg == nil) != (tt.want == nil) {
+			t.Errorf("%s %q: testpaths = %v, want %v", tt.file, tt.content, got, tt.want)
+		}
+	}
+}
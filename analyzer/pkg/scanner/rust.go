@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// rustState is the Cargo context a directory inherits from its parents
+type rustState struct {
+	// crateRoot is the slash-separated repo-relative directory of the
+	// enclosing crate's Cargo.toml, "" outside any crate
+	crateRoot string
+	crateName string
+	// workspaceRoot is the enclosing Cargo workspace, and members its
+	// member globs relative to the workspace root
+	workspaceRoot string
+	members       []string
+	// crateWorkspace is the workspace the enclosing crate is a member of
+	crateWorkspace string
+}
+
+// Key identifies the state for cache fingerprints
+func (r rustState) Key() string {
+	return r.crateRoot + ";" + r.crateName + ";" + r.crateWorkspace
+}
+
+// Child returns the state for a subdirectory, which inherits the crate
+func (r rustState) Child(name string) DirState {
+	return r
+}
+
+// enterDir applies a directory's Cargo.toml to the state inherited from its
+// parent. A manifest with [package] starts a new crate; one with
+// [workspace] starts a new workspace.
+func (r rustState) enterDir(dir *Dir) rustState {
+	hasManifest := false
+	for _, entry := range dir.Entries {
+		if entry.Name() == "Cargo.toml" && !entry.IsDir() {
+			hasManifest = true
+			break
+		}
+	}
+	if !hasManifest {
+		return r
+	}
+
+	relSlash := filepath.ToSlash(dir.RelPath)
+	if ws, ok := readTOMLSection(dir, "Cargo.toml", "workspace"); ok {
+		r.workspaceRoot = relSlash
+		r.members = ws["members"]
+	}
+	if pkg, ok := readTOMLSection(dir, "Cargo.toml", "package"); ok {
+		r.crateRoot = relSlash
+		r.crateName = strings.Join(pkg["name"], " ")
+		r.crateWorkspace = ""
+		if r.workspaceRoot != "" && r.isMember(relSlash) {
+			r.crateWorkspace = r.workspaceRoot
+		}
+	}
+	return r
+}
+
+// isMember reports whether a crate directory matches the workspace members
+func (r rustState) isMember(relSlash string) bool {
+	if relSlash == r.workspaceRoot {
+		return true // the root package of a workspace is always a member
+	}
+	for _, member := range r.members {
+		pattern := path.Join(r.workspaceRoot, member)
+		if ok, _ := path.Match(pattern, relSlash); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inIntegrationTests reports whether a directory is inside its crate's
+// tests/ directory, where every .rs file is an integration test
+func (r rustState) inIntegrationTests(relSlash string) bool {
+	if r.crateRoot == "" {
+		return false
+	}
+	tests := path.Join(r.crateRoot, "tests")
+	return relSlash == tests || strings.HasPrefix(relSlash, tests+"/")
+}
+
+// rustTestAttr matches #[test], #[tokio::test]-style attributes and
+// #[cfg(test)] or #[cfg(all(..., test, ...))] modules, but not
+// #[cfg(not(test))] or features named like tests
+var rustTestAttr = regexp.MustCompile(`#\[\s*(?:cfg\s*\(\s*(?:all\s*\(\s*(?:(?:[^()\]]|\([^()]*\))*,\s*)?)?test\s*[,)]|(?:\w+::)*test\s*[\](])`)
+
+// hasInlineRustTests reports whether a .rs file contains unit tests
+func hasInlineRustTests(dir *Dir, filename string) bool {
+	data, err := dir.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	return rustTestAttr.Match(data)
+}
+
+// rustDetector groups Rust files by Cargo crate
+type rustDetector struct{}
+
+func (rustDetector) Language() Language { return LangRust }
+func (rustDetector) Name() string       { return "Rust" }
+
+func (rustDetector) RuleKinds() map[RuleRole][]string {
+	return map[RuleRole][]string{
+		RoleTest:    {"rust_test"},
+		RoleLibrary: {"rust_library"},
+		RoleBinary:  {"rust_binary"},
+	}
+}
+
+func (rustDetector) Owns(filename string) bool {
+	return strings.HasSuffix(filename, ".rs")
+}
+
+func (rustDetector) EnterDir(parent DirState, dir *Dir) DirState {
+	r, _ := parent.(rustState)
+	return r.enterDir(dir)
+}
+
+// ClassifyFile treats files under a crate's tests/ as integration tests;
+// other files are sources, which hold tests too when they have unit tests
+func (rustDetector) ClassifyFile(dir *Dir, filename string) (FileKind, ExclusionReason) {
+	if dir.State.(rustState).inIntegrationTests(filepath.ToSlash(dir.RelPath)) {
+		return FileTest, ""
+	}
+	if hasInlineRustTests(dir, filename) {
+		return FileSourceWithTests, ""
+	}
+	return FileSource, ""
+}
+
+func (rustDetector) InitPackage(dir *Dir, pkg *Package) {}
+
+// Finish groups files inside a Cargo crate under the crate root.
+// Directories outside any crate are packages on their own.
+func (rustDetector) Finish(dirs []*DirResult) ([]*Package, []*ExcludedPackage) {
+	pkgs := mergeUnderRoots(LangRust, dirs, func(dr *DirResult) string {
+		return dr.State.(rustState).crateRoot
+	}, func(dr *DirResult, crate *Package) {
+		r := dr.State.(rustState)
+		crate.CrateName = r.crateName
+		crate.CargoWorkspace = r.crateWorkspace
+	})
+
+	// A rust_test target means the package has tests even if none were
+	// found in its files
+	for _, pkg := range pkgs {
+		if pkg.TestTargetCount > 0 {
+			pkg.HasTestFiles = true
+		}
+	}
+	return pkgs, nil
+}
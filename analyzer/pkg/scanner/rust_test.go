@@ -0,0 +1,28 @@
+package scanner
+
+import "testing"
+
+func TestRustTestAttr(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"#[test]\nfn it_works() {}", true},
+		{"#[tokio::test]\nasync fn it_works() {}", true},
+		{"#[test_case(1)]\nfn cases(n: u32) {}", false},
+		{"#[cfg(test)]\nmod tests {}", true},
+		{"#[cfg( test )]\nmod tests {}", true},
+		{"#[cfg(all(test, feature = \"slow\"))]\nmod tests {}", true},
+		{"#[cfg(all(unix, not(miri), test))]\nmod tests {}", true},
+		{"#[cfg(not(test))]\nfn real_clock() {}", false},
+		{"#[cfg(all(unix, not(test)))]\nfn real_clock() {}", false},
+		{"#[cfg(feature = \"test-utils\")]\npub mod fixtures;", false},
+		{"#[cfg(all(unix, feature = \"test\"))]\npub mod fixtures;", false},
+		{"#[cfg(testing)]\nmod tests {}", false},
+	}
+	for _, tt := range tests {
+		if got := rustTestAttr.MatchString(tt.src); got != tt.want {
+			t.Errorf("rustTestAttr.MatchString(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package scanner
 
 import (
-	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"sort"
-	"strings"
 )
 
 // Language represents a programming language
@@ -16,74 +18,262 @@ const (
 	LangGo     Language = "go"
 	LangPython Language = "python"
 	LangRust   Language = "rust"
+	LangJava   Language = "java" // Java and Kotlin
+	// LangTypeScript covers TypeScript and JavaScript
+	LangTypeScript Language = "typescript"
+	// LangCpp covers C and C++
+	LangCpp Language = "cpp"
 )
 
 // Package represents a package directory with its metadata
 type Package struct {
-	Path             string   `json:"path"`
-	RelPath          string   `json:"relPath"`
-	Language         Language `json:"language"`
-	HasBuildFile     bool     `json:"hasBuildFile"`
-	HasTestFiles     bool     `json:"hasTestFiles"`
-	SourceFileCount  int      `json:"sourceFileCount"`
-	TestFileCount    int      `json:"testFileCount"`
-	TestTargetCount  int      `json:"testTargetCount"`
-	LibraryTargets   int      `json:"libraryTargetCount"`
-	BinaryTargets    int      `json:"binaryTargetCount"`
+	Path            string   `json:"path"`
+	RelPath         string   `json:"relPath"`
+	Language        Language `json:"language"`
+	HasBuildFile    bool     `json:"hasBuildFile"`
+	HasTestFiles    bool     `json:"hasTestFiles"`
+	SourceFileCount int      `json:"sourceFileCount"`
+	TestFileCount   int      `json:"testFileCount"`
+	TestTargetCount int      `json:"testTargetCount"`
+	LibraryTargets  int      `json:"libraryTargetCount"`
+	BinaryTargets   int      `json:"binaryTargetCount"`
+	// GeneratedFileCount counts the source files that are generated code,
+	// by their "Code generated ... DO NOT EDIT." header or their name. They
+	// are left out of SourceFileCount and of file coverage.
+	GeneratedFileCount int `json:"generatedFileCount,omitempty"`
+
+	// Go: the enclosing module and the package's import path.
+	// Python: the dotted module path of a regular (__init__.py) package.
+	ModulePath string `json:"modulePath,omitempty"`
+	ImportPath string `json:"importPath,omitempty"`
+	// Go only, from the go list backend
+	CgoFileCount   int      `json:"cgoFileCount,omitempty"`
+	EmbedFileCount int      `json:"embedFileCount,omitempty"`
+	Imports        []string `json:"imports,omitempty"`
+
+	// Go only: the import path implied by `# gazelle:prefix` and the
+	// `# gazelle:go_naming_convention` in effect
+	GazelleImportPath  string `json:"gazelleImportPath,omitempty"`
+	GoNamingConvention string `json:"goNamingConvention,omitempty"`
+
+	// Python only: the enclosing project root (pyproject.toml, setup.cfg or
+	// setup.py) and files that are neither sources nor tests, such as
+	// __init__.py, conftest.py, setup.py and helpers under tests/
+	ProjectRoot      string `json:"projectRoot,omitempty"`
+	SupportFileCount int    `json:"supportFileCount,omitempty"`
+
+	// Rust only: the crate name from Cargo.toml and the Cargo workspace the
+	// crate belongs to. Crate packages cover every directory below the
+	// crate root.
+	CrateName      string `json:"crateName,omitempty"`
+	CargoWorkspace string `json:"cargoWorkspace,omitempty"`
+
+	// Java only: "maven" or "gradle" for packages that are a Maven or Gradle
+	// module, covering all of the module's source sets
+	BuildTool string `json:"buildTool,omitempty"`
+
+	// TypeScript only: the name from the package's package.json
+	NpmPackage string `json:"npmPackage,omitempty"`
+
+	// Targets are the rules of the package's BUILD files, whatever their
+	// language, so filegroups, genrules and the like are listed too
+	Targets []*Target `json:"targets,omitempty"`
+
+	// BazelPackage is the label of the Bazel package owning the package's
+	// directory, the nearest one with a BUILD file, and Ownership tells
+	// whether the package is built through it
+	BazelPackage string    `json:"bazelPackage,omitempty"`
+	Ownership    Ownership `json:"ownership"`
+	// Workspace is the relPath of the Bazel workspace the package belongs to
+	Workspace string `json:"workspace"`
+
+	// UncoveredTestFiles are the test files no test target's srcs list, and
+	// UncoveredSourceFiles the source files no target of the language lists,
+	// relative to the package
+	UncoveredTestFiles   []string `json:"uncoveredTestFiles,omitempty"`
+	UncoveredSourceFiles []string `json:"uncoveredSourceFiles,omitempty"`
+
+	// listedGeneratedFiles counts the generated sources some target of the
+	// language lists
+	listedGeneratedFiles int
 }
 
 // ScanResult contains the complete scan results
 type ScanResult struct {
 	RepoPath string `json:"repoPath"`
 
-	// Per-language packages
-	GoPackages     []*Package `json:"goPackages"`
-	PythonPackages []*Package `json:"pythonPackages"`
-	RustPackages   []*Package `json:"rustPackages"`
+	// Packages and totals of every registered language, in registration order
+	Languages []*LanguageResult `json:"languages"`
+
+	// Directories with .proto files
+	ProtoPackages []*ProtoPackage `json:"protoPackages"`
+
+	// Every named target in the scanned BUILD files, sorted by label
+	Targets []*Target `json:"targets"`
 
 	// Totals
 	TotalBUILDs int `json:"totalBuildFiles"`
+	// BuildPackages counts directories with a BUILD file
+	BuildPackages int `json:"buildPackages"`
+
+	// The Bazel package tree: every directory with a BUILD file and the
+	// source directories it owns
+	BazelPackages []*BazelPackage `json:"bazelPackages"`
+
+	// Bazel packages whose srcs, hdrs or data are out of date with the files
+	// on disk
+	StaleBuilds []*StaleBuild `json:"staleBuilds"`
+
+	// External repositories from MODULE.bazel and WORKSPACE, nil when the
+	// repository has neither, nor a .bazelversion
+	External *ExternalDeps `json:"external,omitempty"`
+
+	// Workspaces are the root workspace followed by the nested workspaces,
+	// sorted by path
+	Workspaces []*Workspace `json:"workspaces"`
+
+	// Packages left out of the scan by ignore rules
+	ExcludedPackages []*ExcludedPackage `json:"excludedPackages"`
+
+	// GoBackend is how Go packages were discovered
+	GoBackend GoBackend `json:"goBackend"`
+
+	// Cache hit/miss counts, nil when the scan cache is disabled
+	Cache *CacheStats `json:"cache,omitempty"`
+}
+
+// Language returns the result for a language, or nil if it is not registered
+func (r *ScanResult) Language(lang Language) *LanguageResult {
+	for _, lr := range r.Languages {
+		if lr.Language == lang {
+			return lr
+		}
+	}
+	return nil
+}
+
+// Packages returns the packages of a language
+func (r *ScanResult) Packages(lang Language) []*Package {
+	if lr := r.Language(lang); lr != nil {
+		return lr.Packages
+	}
+	return nil
+}
 
-	// Go totals
-	TotalGoFiles     int `json:"totalGoFiles"`
-	TotalGoTests     int `json:"totalGoTestFiles"`
-	TotalGoTestRules int `json:"totalGoTestRules"`
+// ExclusionReason explains why a path was left out of the scan
+type ExclusionReason string
 
-	// Python totals
-	TotalPythonFiles     int `json:"totalPythonFiles"`
-	TotalPythonTests     int `json:"totalPythonTestFiles"`
-	TotalPyTestRules     int `json:"totalPyTestRules"`
+const (
+	ExcludedByBazelignore ExclusionReason = "bazelignore"
+	ExcludedByGitignore   ExclusionReason = "gitignore"
+)
 
-	// Rust totals
-	TotalRustFiles     int `json:"totalRustFiles"`
-	TotalRustTests     int `json:"totalRustTestFiles"`
-	TotalRustTestRules int `json:"totalRustTestRules"`
+// ExcludedPackage is a package that would have been scanned if not for an ignore rule
+type ExcludedPackage struct {
+	RelPath  string          `json:"relPath"`
+	Language Language        `json:"language"`
+	Reason   ExclusionReason `json:"reason"`
 }
 
 // Scanner scans a repository for Bazel and language metrics
 type Scanner struct {
 	repoPath string
 	skipDirs map[string]bool
+	config   *Config
+	rules    *ruleClassifier
+	// languages are the registered language detectors
+	languages []LanguageDetector
+	// goBuildTags are the custom Go build tags from the config
+	goBuildTags map[string]bool
+	goBackend   GoBackend
+	workers     int
+	// generatedPatterns are the built-in and configured generated file
+	// name patterns
+	generatedPatterns []string
+
+	// Scan cache; disabled when cachePath is empty
+	cachePath  string
+	fullRescan bool
+
+	// source, gitIndex and gitTree replace the working tree under repoPath
+	// with another file system; fsys is the one being scanned, set up by
+	// ScanContext
+	source   fs.FS
+	gitIndex bool
+	gitTree  string
+	fsys     fs.FS
+}
+
+// Option configures a Scanner
+type Option func(*Scanner)
+
+// WithConfig applies an analyzer config, e.g. custom rule kind mappings
+func WithConfig(cfg *Config) Option {
+	return func(s *Scanner) {
+		s.config = cfg
+	}
+}
 
-	// Go regex patterns
-	goTestRegex *regexp.Regexp
-	goLibRegex  *regexp.Regexp
-	goBinRegex  *regexp.Regexp
+// WithWorkers sets the number of goroutines used to walk directories and
+// parse BUILD files. Values below 1 use runtime.NumCPU().
+func WithWorkers(n int) Option {
+	return func(s *Scanner) {
+		s.workers = n
+	}
+}
+
+// WithCache persists per-directory results to path and reuses them for
+// unchanged directories on the next scan. With fullRescan, the existing
+// cache is ignored but a fresh one is still written.
+func WithCache(path string, fullRescan bool) Option {
+	return func(s *Scanner) {
+		s.cachePath = path
+		s.fullRescan = fullRescan
+	}
+}
 
-	// Python regex patterns
-	pyTestRegex *regexp.Regexp
-	pyLibRegex  *regexp.Regexp
-	pyBinRegex  *regexp.Regexp
+// WithGoBackend selects how Go packages are discovered
+func WithGoBackend(backend GoBackend) Option {
+	return func(s *Scanner) {
+		s.goBackend = backend
+	}
+}
 
-	// Rust regex patterns
-	rustTestRegex *regexp.Regexp
-	rustLibRegex  *regexp.Regexp
-	rustBinRegex  *regexp.Regexp
+// WithFS scans a file system, e.g. an archive from OpenArchive or an
+// fstest.MapFS, instead of the directory tree under the repository path.
+// The repository path is still used to label packages.
+func WithFS(fsys fs.FS) Option {
+	return func(s *Scanner) {
+		s.source = fsys
+	}
+}
+
+// WithGitIndex scans the files staged in the git index, with their staged
+// contents, so untracked files and unstaged edits do not affect the result
+func WithGitIndex() Option {
+	return func(s *Scanner) {
+		s.gitIndex = true
+	}
+}
+
+// WithGitTree scans the files of a git tree-ish, e.g. a commit or branch,
+// reading them from git's object store without checking them out
+func WithGitTree(treeish string) Option {
+	return func(s *Scanner) {
+		s.gitTree = treeish
+	}
+}
+
+// WithLanguage registers a language detector on top of the built-in ones
+func WithLanguage(d LanguageDetector) Option {
+	return func(s *Scanner) {
+		s.languages = append(s.languages, d)
+	}
 }
 
 // NewScanner creates a new scanner for the given repository path
-func NewScanner(repoPath string) *Scanner {
-	return &Scanner{
+func NewScanner(repoPath string, opts ...Option) *Scanner {
+	s := &Scanner{
 		repoPath: repoPath,
 		skipDirs: map[string]bool{
 			".git":           true,
@@ -98,254 +288,316 @@ func NewScanner(repoPath string) *Scanner {
 			".venv":          true,
 			"venv":           true,
 		},
-		// Go patterns
-		goTestRegex: regexp.MustCompile(`(?m)^\s*go_test\s*\(`),
-		goLibRegex:  regexp.MustCompile(`(?m)^\s*go_library\s*\(`),
-		goBinRegex:  regexp.MustCompile(`(?m)^\s*go_binary\s*\(`),
-		// Python patterns
-		pyTestRegex: regexp.MustCompile(`(?m)^\s*py_test\s*\(`),
-		pyLibRegex:  regexp.MustCompile(`(?m)^\s*py_library\s*\(`),
-		pyBinRegex:  regexp.MustCompile(`(?m)^\s*py_binary\s*\(`),
-		// Rust patterns
-		rustTestRegex: regexp.MustCompile(`(?m)^\s*rust_test\s*\(`),
-		rustLibRegex:  regexp.MustCompile(`(?m)^\s*rust_library\s*\(`),
-		rustBinRegex:  regexp.MustCompile(`(?m)^\s*rust_binary\s*\(`),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.goBackend == "" {
+		s.goBackend = GoBackendWalk
+	}
+	if s.workers < 1 {
+		s.workers = runtime.NumCPU()
+	}
+	s.goBuildTags = make(map[string]bool)
+	if s.config != nil {
+		for _, tag := range s.config.GoBuildTags {
+			s.goBuildTags[tag] = true
+		}
+	}
+	s.generatedPatterns = defaultGeneratedPatterns()
+	if s.config != nil {
+		s.generatedPatterns = append(s.generatedPatterns, s.config.GeneratedFiles...)
+	}
+	s.languages = append(s.defaultLanguages(), s.languages...)
+	s.rules = newRuleClassifier(s.languages, s.config)
+	return s
 }
 
 // dirPackages holds package info for a single directory, per language
 type dirPackages struct {
-	path        string
-	relPath     string
-	hasBuild    bool
-	targets     *buildTargets
-	goPkg       *Package
-	pythonPkg   *Package
-	rustPkg     *Package
+	path     string
+	relPath  string
+	hasBuild bool
+	// buildFiles counts BUILD and BUILD.bazel files in the directory
+	buildFiles int
+	targets    buildTargets
+	// targetList holds the named targets of the directory's BUILD files
+	targetList []*Target
+	// pkgs holds the directory's package per language, and files the names
+	// of its source and test files
+	pkgs  map[Language]*Package
+	files map[Language]*dirFiles
+	// proto is set when the directory has .proto files, protoc output or
+	// proto rules
+	proto *protoDir
+
+	// excluded is set when the whole directory is ignored
+	excluded ExclusionReason
+	// gazelle holds the gazelle directives in effect, and gazelleIgnore is
+	// set when the directory's BUILD file has `# gazelle:ignore`
+	gazelle       *gazelleConfig
+	gazelleIgnore bool
+	// workspace is the Bazel workspace the directory belongs to
+	workspace *workspaceState
+	// states holds the directory's state per language
+	states map[Language]DirState
+
+	// excludedLangs records languages that had files dropped by ignore rules
+	excludedLangs map[Language]ExclusionReason
+}
+
+// exclude records that a file of the given language was ignored
+func (dp *dirPackages) exclude(lang Language, reason ExclusionReason) {
+	if dp.excludedLangs == nil {
+		dp.excludedLangs = make(map[Language]ExclusionReason)
+	}
+	if _, ok := dp.excludedLangs[lang]; !ok {
+		dp.excludedLangs[lang] = reason
+	}
 }
 
 // Scan performs a full scan of the repository
 func (s *Scanner) Scan() (*ScanResult, error) {
+	return s.ScanContext(context.Background())
+}
+
+// ScanContext performs a full scan of the repository, walking directories
+// and parsing BUILD files on a bounded worker pool. It returns ctx's error if
+// ctx is cancelled before the scan completes.
+func (s *Scanner) ScanContext(ctx context.Context) (*ScanResult, error) {
+	if err := s.checkConfig(); err != nil {
+		return nil, err
+	}
+	fsys, err := s.openFS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if g, ok := fsys.(*gitFS); ok {
+		defer g.Close()
+	}
+	s.fsys = fsys
+
 	result := &ScanResult{
-		RepoPath:       s.repoPath,
-		GoPackages:     make([]*Package, 0),
-		PythonPackages: make([]*Package, 0),
-		RustPackages:   make([]*Package, 0),
+		RepoPath:         s.repoPath,
+		Languages:        make([]*LanguageResult, 0, len(s.languages)),
+		ProtoPackages:    make([]*ProtoPackage, 0),
+		Targets:          make([]*Target, 0),
+		ExcludedPackages: make([]*ExcludedPackage, 0),
 	}
 
-	dirMap := make(map[string]*dirPackages)
+	state := &walkState{root: rootWorkspace(s.fsys)}
+	if s.cachePath != "" {
+		state.cache = openCache(s.cachePath, s.cacheSettings(state.root.bazelIgnores), s.fullRescan)
+	}
 
-	err := filepath.Walk(s.repoPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip files we can't access
+	dirs, err := s.walk(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	// The walk finishes directories in no particular order; everything
+	// built from them, like the targets and files of merged packages,
+	// follows the directory order
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].relPath < dirs[j].relPath
+	})
+	result.External = state.root.ws.External
+	result.Workspaces = collectWorkspaces(state.root, dirs)
+
+	result.GoBackend = GoBackendWalk
+	if s.goBackend == GoBackendGoList && !s.scansWorkingTree() {
+		fmt.Fprintf(os.Stderr, "Warning: go list needs a working tree, using the directory walker for Go packages\n")
+	} else if s.goBackend == GoBackendGoList {
+		result.GoBackend = s.applyGoList(ctx, dirs)
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
+	}
 
-		// Skip hidden and excluded directories
-		if info.IsDir() {
-			base := filepath.Base(path)
-			if strings.HasPrefix(base, ".") || s.skipDirs[base] || strings.HasPrefix(base, "bazel-") {
-				return filepath.SkipDir
-			}
-			return nil
+	applyGazelle(dirs)
+	applyFileCoverage(dirs)
+	result.StaleBuilds = findStaleBuilds(s.fsys, dirs)
+
+	if state.cache != nil {
+		result.Cache = state.cache.stats()
+		if err := state.cache.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write scan cache %s: %v\n", s.cachePath, err)
 		}
+	}
 
-		dir := filepath.Dir(path)
-		relDir, err := filepath.Rel(s.repoPath, dir)
-		if err != nil || relDir == "" {
-			relDir = "."
+	for _, dp := range dirs {
+		result.TotalBUILDs += dp.buildFiles
+		if dp.hasBuild {
+			result.BuildPackages++
+		}
+		result.Targets = append(result.Targets, dp.targetList...)
+		if pkg := dp.protoPackage(); pkg != nil {
+			result.ProtoPackages = append(result.ProtoPackages, pkg)
 		}
 
-		// Get or create dir entry
-		dp, exists := dirMap[dir]
-		if !exists {
-			dp = &dirPackages{
-				path:    dir,
-				relPath: relDir,
+		// Report languages whose files were all ignored in this directory
+		for lang, reason := range dp.excludedLangs {
+			if dp.pkgs[lang] == nil {
+				result.ExcludedPackages = append(result.ExcludedPackages, &ExcludedPackage{
+					RelPath:  dp.relPath,
+					Language: lang,
+					Reason:   reason,
+				})
 			}
-			dirMap[dir] = dp
 		}
+	}
 
-		filename := filepath.Base(path)
+	for _, d := range s.languages {
+		lr, excluded := s.finishLanguage(d, dirs)
+		result.Languages = append(result.Languages, lr)
+		result.ExcludedPackages = append(result.ExcludedPackages, excluded...)
+	}
 
-		// Check for BUILD files
-		if filename == "BUILD" || filename == "BUILD.bazel" {
-			dp.hasBuild = true
-			result.TotalBUILDs++
+	byRel := indexDirs(dirs)
+	result.BazelPackages = buildPackageTree(dirs, byRel)
+	applyOwnership(result.Languages, byRel)
+	applyWorkspaces(result.Languages, byRel)
 
-			// Parse BUILD file for targets
-			targets, err := s.parseBuildFile(path)
-			if err == nil {
-				dp.targets = targets
-			}
+	// Sort packages by path for deterministic output
+	for _, lr := range result.Languages {
+		sort.Slice(lr.Packages, func(i, j int) bool {
+			return lr.Packages[i].RelPath < lr.Packages[j].RelPath
+		})
+	}
+	sort.Slice(result.Targets, func(i, j int) bool {
+		return result.Targets[i].Label < result.Targets[j].Label
+	})
+	sort.Slice(result.ProtoPackages, func(i, j int) bool {
+		return result.ProtoPackages[i].RelPath < result.ProtoPackages[j].RelPath
+	})
+	sort.Slice(result.ExcludedPackages, func(i, j int) bool {
+		a, b := result.ExcludedPackages[i], result.ExcludedPackages[j]
+		if a.RelPath != b.RelPath {
+			return a.RelPath < b.RelPath
 		}
+		return a.Language < b.Language
+	})
 
-		// Check for Go files
-		if strings.HasSuffix(filename, ".go") {
-			if dp.goPkg == nil {
-				dp.goPkg = &Package{
-					Path:     dir,
-					RelPath:  relDir,
-					Language: LangGo,
-				}
-			}
-			if strings.HasSuffix(filename, "_test.go") {
-				dp.goPkg.HasTestFiles = true
-				dp.goPkg.TestFileCount++
-				result.TotalGoTests++
-			} else {
-				dp.goPkg.SourceFileCount++
-				result.TotalGoFiles++
-			}
-		}
+	return result, nil
+}
 
-		// Check for Python files
-		if strings.HasSuffix(filename, ".py") {
-			if dp.pythonPkg == nil {
-				dp.pythonPkg = &Package{
-					Path:     dir,
-					RelPath:  relDir,
-					Language: LangPython,
-				}
-			}
-			// Python test patterns: *_test.py, test_*.py, *_tests.py
-			if strings.HasSuffix(filename, "_test.py") ||
-				strings.HasPrefix(filename, "test_") ||
-				strings.HasSuffix(filename, "_tests.py") {
-				dp.pythonPkg.HasTestFiles = true
-				dp.pythonPkg.TestFileCount++
-				result.TotalPythonTests++
-			} else {
-				dp.pythonPkg.SourceFileCount++
-				result.TotalPythonFiles++
-			}
-		}
+// openFS returns the file system to scan: the one given with WithFS, the
+// files git tracks in a tree-ish or the index, or the directory tree under
+// repoPath
+func (s *Scanner) openFS(ctx context.Context) (fs.FS, error) {
+	switch {
+	case s.source != nil:
+		return s.source, nil
+	case s.gitTree != "":
+		return openGitTree(ctx, s.repoPath, s.gitTree)
+	case s.gitIndex:
+		return openGitIndex(ctx, s.repoPath)
+	}
+	return os.DirFS(s.repoPath), nil
+}
 
-		// Check for Rust files
-		if strings.HasSuffix(filename, ".rs") {
-			if dp.rustPkg == nil {
-				dp.rustPkg = &Package{
-					Path:     dir,
-					RelPath:  relDir,
-					Language: LangRust,
-				}
-			}
-			// Rust doesn't have separate test files - tests are usually inline
-			// We'll count all .rs files as source files
-			dp.rustPkg.SourceFileCount++
-			result.TotalRustFiles++
-		}
+// scansTrackedFiles reports whether the scan lists the files git tracks.
+// Git never ignores a tracked file, so .gitignore rules do not apply to them.
+func (s *Scanner) scansTrackedFiles() bool {
+	_, ok := s.fsys.(*gitFS)
+	return ok
+}
 
-		return nil
-	})
+// scansWorkingTree reports whether the scan reads the directory tree under
+// repoPath, which tools like go list need
+func (s *Scanner) scansWorkingTree() bool {
+	return s.source == nil && !s.gitIndex && s.gitTree == ""
+}
 
-	if err != nil {
-		return nil, err
+// checkConfig verifies that config rule mappings name registered languages
+// and that generated file patterns are valid
+func (s *Scanner) checkConfig() error {
+	if s.config == nil {
+		return nil
 	}
-
-	// Process all directories and assign BUILD targets
-	for _, dp := range dirMap {
-		// Assign BUILD file info and targets to packages
-		if dp.goPkg != nil {
-			dp.goPkg.HasBuildFile = dp.hasBuild
-			if dp.targets != nil {
-				dp.goPkg.TestTargetCount = dp.targets.goTests
-				dp.goPkg.LibraryTargets = dp.targets.goLibs
-				dp.goPkg.BinaryTargets = dp.targets.goBins
-				result.TotalGoTestRules += dp.targets.goTests
-			}
-			result.GoPackages = append(result.GoPackages, dp.goPkg)
+	for i, m := range s.config.Rules {
+		known := false
+		for _, d := range s.languages {
+			known = known || d.Language() == m.Language
+		}
+		if !known {
+			return fmt.Errorf("config: rules[%d] (%s): unknown language %q", i, m.Kind, m.Language)
+		}
+	}
+	for i, pattern := range s.config.GeneratedFiles {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("config: generatedFiles[%d]: invalid pattern %q", i, pattern)
 		}
+	}
+	return nil
+}
 
-		if dp.pythonPkg != nil {
-			dp.pythonPkg.HasBuildFile = dp.hasBuild
-			if dp.targets != nil {
-				dp.pythonPkg.TestTargetCount = dp.targets.pyTests
-				dp.pythonPkg.LibraryTargets = dp.targets.pyLibs
-				dp.pythonPkg.BinaryTargets = dp.targets.pyBins
-				result.TotalPyTestRules += dp.targets.pyTests
-			}
-			result.PythonPackages = append(result.PythonPackages, dp.pythonPkg)
+// scanFile classifies a single file of a directory
+func (s *Scanner) scanFile(dp *dirPackages, ignore *gitignore, entries []os.DirEntry, filename string) {
+	// Ignored files are only tallied so excluded packages can be reported
+	reason := dp.excluded
+	relSlash := filepath.ToSlash(filepath.Join(dp.relPath, filename))
+	if reason == "" && ignore.ignored(relSlash, false) {
+		reason = ExcludedByGitignore
+	}
+	if reason == "" && dp.gazelle.excluded(relSlash) {
+		reason = ExcludedByGazelle
+	}
+	if reason != "" {
+		if d := s.detectorFor(filename); d != nil {
+			dp.exclude(d.Language(), reason)
 		}
+		return
+	}
 
-		if dp.rustPkg != nil {
-			dp.rustPkg.HasBuildFile = dp.hasBuild
-			if dp.targets != nil {
-				dp.rustPkg.TestTargetCount = dp.targets.rustTests
-				dp.rustPkg.LibraryTargets = dp.targets.rustLibs
-				dp.rustPkg.BinaryTargets = dp.targets.rustBins
-				result.TotalRustTestRules += dp.targets.rustTests
-				// For Rust, if there are rust_test targets, mark as having tests
-				if dp.targets.rustTests > 0 {
-					dp.rustPkg.HasTestFiles = true
-					dp.rustPkg.TestFileCount = dp.targets.rustTests
-					result.TotalRustTests += dp.targets.rustTests
-				}
+	// Check for BUILD files
+	if isBuildFileName(filename) {
+		dp.hasBuild = true
+		dp.buildFiles++
+
+		// Only the BUILD file Bazel reads defines targets; a BUILD next to a
+		// BUILD.bazel is ignored even if BUILD.bazel does not parse
+		if filename == buildFileName(entries) {
+			if bf, err := parseBuildFile(s.fsys, dp.file(filename)); err == nil {
+				dp.targets = s.countTargets(bf)
+				dp.targetList = s.buildTargetList(dp.workspace.packageLabel(dp.relPath), bf)
+				dp.addProtoRules(bf)
 			}
-			result.RustPackages = append(result.RustPackages, dp.rustPkg)
 		}
 	}
 
-	// Sort packages by path for deterministic output
-	sort.Slice(result.GoPackages, func(i, j int) bool {
-		return result.GoPackages[i].RelPath < result.GoPackages[j].RelPath
-	})
-	sort.Slice(result.PythonPackages, func(i, j int) bool {
-		return result.PythonPackages[i].RelPath < result.PythonPackages[j].RelPath
-	})
-	sort.Slice(result.RustPackages, func(i, j int) bool {
-		return result.RustPackages[i].RelPath < result.RustPackages[j].RelPath
-	})
-
-	return result, nil
+	dp.addProtoFile(s.fsys, filename)
+	s.addFile(dp, entries, filename)
 }
 
-type buildTargets struct {
-	// Go targets
-	goTests int
-	goLibs  int
-	goBins  int
-	// Python targets
-	pyTests int
-	pyLibs  int
-	pyBins  int
-	// Rust targets
-	rustTests int
-	rustLibs  int
-	rustBins  int
+// file returns the path of one of the directory's files in the scanned file
+// system
+func (dp *dirPackages) file(name string) string {
+	return path.Join(filepath.ToSlash(dp.relPath), name)
 }
 
-func (s *Scanner) parseBuildFile(path string) (*buildTargets, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+// newPackage creates an empty package of the given language for the directory
+func (dp *dirPackages) newPackage(lang Language) *Package {
+	return &Package{
+		Path:     dp.path,
+		RelPath:  dp.relPath,
+		Language: lang,
 	}
-	defer file.Close()
-
-	targets := &buildTargets{}
-	sc := bufio.NewScanner(file)
-	var content strings.Builder
-
-	for sc.Scan() {
-		content.WriteString(sc.Text())
-		content.WriteString("\n")
-	}
-
-	text := content.String()
-
-	// Go targets
-	targets.goTests = len(s.goTestRegex.FindAllString(text, -1))
-	targets.goLibs = len(s.goLibRegex.FindAllString(text, -1))
-	targets.goBins = len(s.goBinRegex.FindAllString(text, -1))
+}
 
-	// Python targets
-	targets.pyTests = len(s.pyTestRegex.FindAllString(text, -1))
-	targets.pyLibs = len(s.pyLibRegex.FindAllString(text, -1))
-	targets.pyBins = len(s.pyBinRegex.FindAllString(text, -1))
+// buildTargets counts the rules of a BUILD file by language and role
+type buildTargets map[ruleClass]int
 
-	// Rust targets
-	targets.rustTests = len(s.rustTestRegex.FindAllString(text, -1))
-	targets.rustLibs = len(s.rustLibRegex.FindAllString(text, -1))
-	targets.rustBins = len(s.rustBinRegex.FindAllString(text, -1))
+func (t buildTargets) count(lang Language, role RuleRole) int {
+	return t[ruleClass{lang: lang, role: role}]
+}
 
-	return targets, sc.Err()
+// countTargets tallies the rules of a BUILD file by language and role
+func (s *Scanner) countTargets(bf *BuildFile) buildTargets {
+	targets := make(buildTargets)
+	for _, rule := range bf.Rules {
+		if class, ok := s.rules.classify(rule); ok {
+			targets[class]++
+		}
+	}
+	return targets
 }
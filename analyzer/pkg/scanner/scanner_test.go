@@ -0,0 +1,321 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// scanFS scans an in-memory repository and fails the test on error
+func scanFS(t *testing.T, fsys fs.FS, opts ...Option) *ScanResult {
+	t.Helper()
+	s := NewScanner("/repo", append([]Option{WithFS(fsys)}, opts...)...)
+	result, err := s.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	return result
+}
+
+// marshal returns the JSON a scan result is reported as
+func marshal(t *testing.T, result *ScanResult) []byte {
+	t.Helper()
+	data, err := json.MarshalIndent(result, "", " ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
+
+// file makes a MapFS file from its content
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+// rustModuleFS is a crate with many subdirectories, each with a BUILD file
+// and an unlisted file, so merged packages collect targets and uncovered
+// files from every directory
+func rustModuleFS(dirs int) fstest.MapFS {
+	fsys := fstest.MapFS{
+		"Cargo.toml": file("[package]\nname = \"big\"\n"),
+		"src/lib.rs": file("pub fn f() {}\n"),
+	}
+	for i := 0; i < dirs; i++ {
+		dir := fmt.Sprintf("src/m%03d", i)
+		fsys[dir+"/mod.rs"] = file("pub fn g() {}\n")
+		fsys[dir+"/extra.rs"] = file("#[cfg(test)]\nmod tests {}\n")
+		fsys[dir+"/BUILD.bazel"] = file(fmt.Sprintf("rust_library(name = \"m%03d\", srcs = [\"mod.rs\"])\n", i))
+	}
+	return fsys
+}
+
+func TestPackageTargetsIncludeEveryRule(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/util.py": file("def f(): pass\n"),
+		"lib/BUILD.bazel": file(`py_library(name = "util", srcs = ["util.py"])
+genrule(name = "gen", outs = ["gen.txt"], cmd = "touch $@")
+filegroup(name = "data", srcs = glob(["*.txt"]))
+sh_test(name = "smoke", srcs = ["smoke.sh"])
+proto_library(name = "api_proto", srcs = ["api.proto"])
+`),
+		"crate/Cargo.toml":           file("[package]\nname = \"crate\"\n"),
+		"crate/src/lib.rs":           file("pub fn f() {}\n"),
+		"crate/BUILD.bazel":          file(`rust_library(name = "crate", srcs = ["src/lib.rs"])` + "\n"),
+		"crate/src/data/BUILD.bazel": file(`filegroup(name = "fixtures", srcs = glob(["*.json"]))` + "\n"),
+	}
+	result := scanFS(t, fsys)
+
+	tests := []struct {
+		lang Language
+		rel  string
+		want []string
+	}{
+		{LangPython, "lib", []string{"//lib:util", "//lib:gen", "//lib:data", "//lib:smoke", "//lib:api_proto"}},
+		{LangRust, "crate", []string{"//crate:crate", "//crate/src/data:fixtures"}},
+	}
+	for _, tt := range tests {
+		var pkg *Package
+		for _, p := range result.Packages(tt.lang) {
+			if p.RelPath == tt.rel {
+				pkg = p
+			}
+		}
+		if pkg == nil {
+			t.Errorf("no %s package at %s", tt.lang, tt.rel)
+			continue
+		}
+		var got []string
+		for _, target := range pkg.Targets {
+			got = append(got, target.Label)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s package %s targets = %v, want %v", tt.lang, tt.rel, got, tt.want)
+		}
+	}
+}
+
+// mixedFS is a small repository with a package per language, a Cargo crate,
+// a Maven module and a gazelle-ignored directory
+func mixedFS() fstest.MapFS {
+	return fstest.MapFS{
+		"MODULE.bazel":     file("module(name = \"mixed\")\n"),
+		"go.mod":           file("module example.com/mixed\n\ngo 1.21\n"),
+		"cmd/main.go":      file("package main\n"),
+		"cmd/main_test.go": file("package main\n"),
+		"cmd/BUILD.bazel": file(`load("@rules_go//go:def.bzl", "go_binary", "go_test")
+go_binary(name = "cmd", srcs = ["main.go"])
+go_test(name = "cmd_test", srcs = ["main_test.go"])
+`),
+		"py/app.py":         file("import os\n"),
+		"py/test_app.py":    file("def test_app(): pass\n"),
+		"py/BUILD":          file(`py_library(name = "app", srcs = ["app.py"])` + "\n"),
+		"crate/Cargo.toml":  file("[package]\nname = \"crate\"\n"),
+		"crate/src/lib.rs":  file("#[cfg(test)]\nmod tests {}\n"),
+		"crate/src/util.rs": file("#[cfg(not(test))]\npub fn now() {}\n"),
+		"crate/BUILD.bazel": file(`rust_library(name = "crate", srcs = ["src/lib.rs"])` + "\n"),
+		"jvm/pom.xml":       file("<project/>\n"),
+		"jvm/src/main/java/com/example/TestDataFactory.java": file("class TestDataFactory {}\n"),
+		"jvm/src/test/java/com/example/AppTest.java":         file("class AppTest {}\n"),
+		"ignored/BUILD.bazel":                                file("# gazelle:ignore\n"),
+		"ignored/tool.py":                                    file("print()\n"),
+	}
+}
+
+// writeTree writes the files of a MapFS under dir
+func writeTree(t *testing.T, dir string, fsys fstest.MapFS) {
+	t.Helper()
+	for name, f := range fsys {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, f.Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeArchive writes the files of a MapFS into an archive under a single
+// top-level directory, the way source archives are laid out
+func writeArchive(t *testing.T, filename string, fsys fstest.MapFS) {
+	t.Helper()
+	out, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	names := make([]string, 0, len(fsys))
+	for name := range fsys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if strings.HasSuffix(filename, ".zip") {
+		zw := zip.NewWriter(out)
+		for _, name := range names {
+			w, err := zw.Create("repo-main/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(fsys[name].Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var w io.Writer = out
+	if strings.HasSuffix(filename, ".gz") {
+		gz := gzip.NewWriter(out)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for _, name := range names {
+		data := fsys[name].Data
+		hdr := &tar.Header{Name: "repo-main/" + name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanArchiveMatchesDirectory(t *testing.T) {
+	fsys := mixedFS()
+	repo := t.TempDir()
+	writeTree(t, repo, fsys)
+	result, err := NewScanner(repo).Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	want := marshal(t, result)
+
+	for _, name := range []string{"repo.tar", "repo.tar.gz", "repo.zip"} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), name)
+			writeArchive(t, archive, fsys)
+			archiveFS, err := OpenArchive(archive)
+			if err != nil {
+				t.Fatalf("OpenArchive: %v", err)
+			}
+			result, err := NewScanner(repo, WithFS(archiveFS)).Scan()
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if got := marshal(t, result); !bytes.Equal(got, want) {
+				t.Errorf("scan of %s differs from the scan of the directory", name)
+			}
+		})
+	}
+}
+
+func TestScanClassification(t *testing.T) {
+	result := scanFS(t, mixedFS())
+	tests := []struct {
+		name   string
+		lang   Language
+		rel    string
+		source int
+		tests  int
+	}{
+		{"test-like class in the main source set", LangJava, "jvm", 1, 1},
+		{"cfg(not(test)) is no inline test", LangRust, "crate", 2, 1},
+		{"go package", LangGo, "cmd", 1, 1},
+		{"python package", LangPython, "py", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, pkg := range result.Packages(tt.lang) {
+				if pkg.RelPath != tt.rel {
+					continue
+				}
+				if pkg.SourceFileCount != tt.source || pkg.TestFileCount != tt.tests {
+					t.Errorf("%s has %d source and %d test files, want %d and %d", tt.rel, pkg.SourceFileCount, pkg.TestFileCount, tt.source, tt.tests)
+				}
+				return
+			}
+			t.Errorf("no %s package at %s", tt.lang, tt.rel)
+		})
+	}
+
+	for _, pkg := range result.Packages(LangPython) {
+		if pkg.RelPath == "ignored" {
+			t.Error("gazelle-ignored directory without targets is reported as a package")
+		}
+	}
+}
+
+func TestOnlyEffectiveBuildFileDefinesTargets(t *testing.T) {
+	legacy := `proto_library(name = "api_proto", srcs = ["api.proto"])
+go_test(name = "legacy_test", srcs = ["a_test.go"])
+`
+	tests := []struct {
+		name         string
+		files        map[string]string
+		wantTargets  []string
+		wantProtoLib bool
+	}{
+		{
+			name:        "BUILD.bazel wins",
+			files:       map[string]string{"BUILD.bazel": `py_library(name = "lib", srcs = ["lib.py"])`, "BUILD": legacy},
+			wantTargets: []string{"//pkg:lib"},
+		},
+		{
+			name:  "unparseable BUILD.bazel",
+			files: map[string]string{"BUILD.bazel": `py_library(name = "lib"`, "BUILD": legacy},
+		},
+		{
+			name:         "BUILD alone",
+			files:        map[string]string{"BUILD": legacy},
+			wantTargets:  []string{"//pkg:api_proto", "//pkg:legacy_test"},
+			wantProtoLib: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"pkg/api.proto": file("syntax = \"proto3\";\npackage api;\n"),
+				"pkg/lib.py":    file("x = 1\n"),
+			}
+			for name, content := range tt.files {
+				fsys["pkg/"+name] = file(content)
+			}
+			result := scanFS(t, fsys)
+
+			var got []string
+			for _, target := range result.Targets {
+				got = append(got, target.Label)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantTargets) {
+				t.Errorf("targets = %v, want %v", got, tt.wantTargets)
+			}
+			for _, pp := range result.ProtoPackages {
+				if pp.HasProtoLibrary != tt.wantProtoLib {
+					t.Errorf("proto package %s has a proto_library: %v, want %v", pp.RelPath, pp.HasProtoLibrary, tt.wantProtoLib)
+				}
+			}
+			if len(result.ProtoPackages) != 1 {
+				t.Errorf("got %d proto packages, want 1", len(result.ProtoPackages))
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// StaleBuild is a Bazel package whose BUILD file is out of date with the
+// files on disk, typically because gazelle was not rerun
+type StaleBuild struct {
+	RelPath string `json:"relPath"`
+	// MissingFiles are srcs, hdrs and data entries naming files that do not
+	// exist
+	MissingFiles []*MissingFile `json:"missingFiles"`
+	// UnlistedFiles are source and test files, relative to the package, that
+	// no rule of the package lists in its srcs, hdrs or data
+	UnlistedFiles []string `json:"unlistedFiles"`
+}
+
+// MissingFile is a file label of a target that points at no file
+type MissingFile struct {
+	Target string `json:"target"`
+	Attr   string `json:"attr"`
+	File   string `json:"file"`
+}
+
+// findStaleBuilds checks every Bazel package for srcs, hdrs and data that
+// point at missing files and for language files no rule lists. Packages with
+// neither are left out.
+func findStaleBuilds(fsys fs.FS, dirs []*dirPackages) []*StaleBuild {
+	byRel := indexDirs(dirs)
+	stale := make(map[*dirPackages]*StaleBuild)
+	staleBuild := func(dp *dirPackages) *StaleBuild {
+		sb, ok := stale[dp]
+		if !ok {
+			sb = &StaleBuild{
+				RelPath:       dp.relPath,
+				MissingFiles:  make([]*MissingFile, 0),
+				UnlistedFiles: make([]string, 0),
+			}
+			stale[dp] = sb
+		}
+		return sb
+	}
+
+	for _, dp := range dirs {
+		if !dp.hasBuild {
+			continue
+		}
+		for _, mf := range missingFiles(fsys, dp) {
+			sb := staleBuild(dp)
+			sb.MissingFiles = append(sb.MissingFiles, mf)
+		}
+	}
+
+	for _, dp := range dirs {
+		if len(dp.files) == 0 {
+			continue
+		}
+		owner, prefix := owningPackage(byRel, filepath.ToSlash(dp.relPath))
+		if owner == nil {
+			continue // not bazelized at all, rather than stale
+		}
+		pkgSlash := owner.pkgPath()
+		for _, files := range dp.files {
+			for _, names := range [][]string{files.Sources, files.Tests} {
+				for _, name := range names {
+					file := path.Join(prefix, name)
+					if !listedByAny(owner.targetList, pkgSlash, file) {
+						sb := staleBuild(owner)
+						sb.UnlistedFiles = append(sb.UnlistedFiles, file)
+					}
+				}
+			}
+		}
+	}
+
+	result := make([]*StaleBuild, 0, len(stale))
+	for _, sb := range stale {
+		sb.UnlistedFiles = dedupSorted(sb.UnlistedFiles)
+		result = append(result, sb)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RelPath < result[j].RelPath
+	})
+	return result
+}
+
+// missingFiles returns the srcs, hdrs and data entries of a package's
+// targets that name neither a file on disk nor a target or output of the
+// package. Entries without an extension, like ":name", are assumed to be
+// targets created by macros.
+func missingFiles(fsys fs.FS, dp *dirPackages) []*MissingFile {
+	pkgSlash := dp.pkgPath()
+	known := make(map[string]bool)
+	for _, t := range dp.targetList {
+		known[t.Name] = true
+		for _, out := range t.Outs {
+			known[out] = true
+		}
+	}
+
+	var missing []*MissingFile
+	for _, t := range dp.targetList {
+		for _, attr := range []struct {
+			name   string
+			labels []string
+		}{{"srcs", t.Srcs}, {"hdrs", t.Hdrs}, {"data", t.Data}} {
+			for _, label := range attr.labels {
+				name, ok := localFileLabel(pkgSlash, label)
+				if !ok || name == "" || known[name] {
+					continue
+				}
+				if path.Ext(name) == "" {
+					continue
+				}
+				if _, err := fs.Stat(fsys, dp.file(name)); err == nil {
+					continue
+				}
+				missing = append(missing, &MissingFile{Target: t.Label, Attr: attr.name, File: name})
+			}
+		}
+	}
+	return missing
+}
+
+// listedByAny reports whether any target lists a package-relative file in
+// its srcs, hdrs or data
+func listedByAny(targets []*Target, pkgSlash, file string) bool {
+	for _, t := range targets {
+		if t.lists(pkgSlash, file) || t.listsData(pkgSlash, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupSorted sorts a list of strings and removes duplicates
+func dedupSorted(list []string) []string {
+	sort.Strings(list)
+	out := list[:0]
+	for i, s := range list {
+		if i == 0 || s != list[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
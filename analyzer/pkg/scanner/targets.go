@@ -0,0 +1,100 @@
+package scanner
+
+// Target is a named rule instance in a BUILD file
+type Target struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	// Language and Role are set when the kind is a known or configured rule
+	Language Language `json:"language,omitempty"`
+	Role     RuleRole `json:"role,omitempty"`
+	// Srcs are the literal srcs entries; SrcGlobs are the glob() calls
+	Srcs     []string `json:"srcs"`
+	SrcGlobs []Glob   `json:"srcGlobs,omitempty"`
+	// Hdrs and HdrGlobs are the same for the hdrs of C and C++ rules
+	Hdrs     []string `json:"hdrs,omitempty"`
+	HdrGlobs []Glob   `json:"hdrGlobs,omitempty"`
+	// Data and DataGlobs are the same for data
+	Data      []string `json:"data,omitempty"`
+	DataGlobs []Glob   `json:"dataGlobs,omitempty"`
+	// SrcsDynamic and DataDynamic are set when srcs or hdrs, respectively
+	// data, could not be evaluated statically
+	SrcsDynamic bool `json:"srcsDynamic,omitempty"`
+	DataDynamic bool `json:"dataDynamic,omitempty"`
+	// Outs are the files the rule declares as outputs, e.g. a genrule's outs
+	Outs []string `json:"outs,omitempty"`
+	// Crate is the crate a rust_test tests
+	Crate      string   `json:"crate,omitempty"`
+	DepsCount  int      `json:"depsCount"`
+	Tags       []string `json:"tags,omitempty"`
+	Size       string   `json:"size,omitempty"`
+	Visibility []string `json:"visibility,omitempty"`
+	// Keep is set when gazelle is told to keep the rule or its attributes
+	Keep bool `json:"keep,omitempty"`
+}
+
+// targetLabel returns the label of a target in a package, given the
+// package's label
+func targetLabel(pkgLabel, name string) string {
+	return pkgLabel + ":" + name
+}
+
+// buildTargetList returns the named targets of a BUILD file in the package
+// labelled pkgLabel. Targets without a visibility attribute get the
+// package's default_visibility.
+func (s *Scanner) buildTargetList(pkgLabel string, bf *BuildFile) []*Target {
+	var defaultVisibility []string
+	for _, rule := range bf.Rules {
+		if rule.Kind == "package" {
+			defaultVisibility = rule.AttrStrings("default_visibility")
+		}
+	}
+
+	var targets []*Target
+	for _, rule := range bf.Rules {
+		if rule.Name == "" {
+			continue
+		}
+		t := &Target{
+			Name:       rule.Name,
+			Kind:       rule.Kind,
+			Label:      targetLabel(pkgLabel, rule.Name),
+			Srcs:       rule.AttrStrings("srcs"),
+			SrcGlobs:   rule.Attrs["srcs"].Globs,
+			Hdrs:       rule.AttrStrings("hdrs"),
+			HdrGlobs:   rule.Attrs["hdrs"].Globs,
+			Data:       rule.AttrStrings("data"),
+			DataGlobs:  rule.Attrs["data"].Globs,
+			Outs:       rule.AttrStrings("outs"),
+			Crate:      rule.AttrString("crate"),
+			DepsCount:  len(rule.AttrStrings("deps")),
+			Tags:       rule.AttrStrings("tags"),
+			Size:       rule.AttrString("size"),
+			Visibility: rule.AttrStrings("visibility"),
+			Keep:       rule.Keep,
+		}
+		t.SrcsDynamic = !isStaticList(rule.Attrs["srcs"]) || !isStaticList(rule.Attrs["hdrs"])
+		t.DataDynamic = !isStaticList(rule.Attrs["data"])
+		if out := rule.AttrString("out"); out != "" {
+			t.Outs = append(t.Outs, out)
+		}
+		if t.Srcs == nil {
+			t.Srcs = make([]string, 0)
+		}
+		if _, ok := rule.Attrs["visibility"]; !ok {
+			t.Visibility = defaultVisibility
+		}
+		if class, ok := s.rules.classify(rule); ok {
+			t.Language = class.lang
+			t.Role = class.role
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// isStaticList reports whether a list attribute was fully evaluated. A scalar
+// value in a list attribute is a variable, like `srcs = SRCS`.
+func isStaticList(attr Attr) bool {
+	return !attr.Dynamic && attr.Value == ""
+}
@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// treeFS is a read-only file system built from a flat list of files, like
+// a git tree or an archive. Directories are implied by the files in them.
+type treeFS struct {
+	files map[string]*treeFile
+	// dirs holds the listing of every directory, sorted by finish
+	dirs map[string][]fs.DirEntry
+}
+
+// treeFile is a file of a treeFS; it implements fs.FileInfo
+type treeFile struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	// size is -1 when it is only known once the file is read
+	size int64
+	sys  any
+	read func() ([]byte, error)
+}
+
+func newTreeFS() *treeFS {
+	return &treeFS{
+		files: make(map[string]*treeFile),
+		dirs:  map[string][]fs.DirEntry{".": nil},
+	}
+}
+
+// addFile adds a file at a slash-separated path. Invalid paths and paths
+// already present are skipped.
+func (t *treeFS) addFile(name string, f *treeFile) {
+	if !fs.ValidPath(name) || name == "." || t.files[name] != nil {
+		return
+	}
+	if _, ok := t.dirs[name]; ok {
+		return
+	}
+	f.name = path.Base(name)
+	t.files[name] = f
+	t.addEntry(path.Dir(name), fs.FileInfoToDirEntry(f))
+}
+
+// addDir adds a directory, which may be empty
+func (t *treeFS) addDir(name string) {
+	if !fs.ValidPath(name) || t.files[name] != nil {
+		return
+	}
+	if _, ok := t.dirs[name]; ok {
+		return
+	}
+	t.dirs[name] = nil
+	t.addEntry(path.Dir(name), fs.FileInfoToDirEntry(treeDirInfo(path.Base(name))))
+}
+
+// addEntry adds an entry to a directory's listing, creating the directory
+// and its parents as needed
+func (t *treeFS) addEntry(dir string, entry fs.DirEntry) {
+	if _, ok := t.dirs[dir]; !ok {
+		t.addDir(dir)
+	}
+	t.dirs[dir] = append(t.dirs[dir], entry)
+}
+
+// finish sorts the directory listings once every file is added
+func (t *treeFS) finish() {
+	for _, entries := range t.dirs {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+	}
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if entries, ok := t.dirs[name]; ok {
+		return &treeDirHandle{info: treeDirInfo(path.Base(name)), entries: append([]fs.DirEntry(nil), entries...)}, nil
+	}
+	f, ok := t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := f.read()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &treeFileHandle{info: f, Reader: bytes.NewReader(data)}, nil
+}
+
+func (t *treeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, ok := t.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]fs.DirEntry(nil), entries...), nil
+}
+
+func (t *treeFS) ReadFile(name string) ([]byte, error) {
+	f, ok := t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := f.read()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+func (t *treeFS) Stat(name string) (fs.FileInfo, error) {
+	if _, ok := t.dirs[name]; ok {
+		return treeDirInfo(path.Base(name)), nil
+	}
+	if f, ok := t.files[name]; ok {
+		return f, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (f *treeFile) Name() string       { return f.name }
+func (f *treeFile) Mode() fs.FileMode  { return f.mode }
+func (f *treeFile) ModTime() time.Time { return f.modTime }
+func (f *treeFile) IsDir() bool        { return false }
+func (f *treeFile) Sys() any           { return f.sys }
+
+// Size reads the file if its size is not known up front
+func (f *treeFile) Size() int64 {
+	if f.size >= 0 {
+		return f.size
+	}
+	data, err := f.read()
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// treeDirInfo is the fs.FileInfo of a directory of a treeFS
+type treeDirInfo string
+
+func (d treeDirInfo) Name() string       { return string(d) }
+func (d treeDirInfo) Size() int64        { return 0 }
+func (d treeDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (d treeDirInfo) ModTime() time.Time { return time.Time{} }
+func (d treeDirInfo) IsDir() bool        { return true }
+func (d treeDirInfo) Sys() any           { return nil }
+
+// treeFileHandle is an open file of a treeFS
+type treeFileHandle struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+func (h *treeFileHandle) Stat() (fs.FileInfo, error) { return h.info, nil }
+func (h *treeFileHandle) Close() error               { return nil }
+
+// treeDirHandle is an open directory of a treeFS
+type treeDirHandle struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+func (h *treeDirHandle) Stat() (fs.FileInfo, error) { return h.info, nil }
+func (h *treeDirHandle) Close() error               { return nil }
+
+func (h *treeDirHandle) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: h.info.Name(), Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the next n entries, or all remaining ones if n <= 0
+func (h *treeDirHandle) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 || n >= len(h.entries) {
+		entries := h.entries
+		h.entries = nil
+		if n > 0 && len(entries) == 0 {
+			return nil, io.EOF
+		}
+		return entries, nil
+	}
+	entries := h.entries[:n]
+	h.entries = h.entries[n:]
+	return entries, nil
+}
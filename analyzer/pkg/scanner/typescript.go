@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tsExtensions are the TypeScript and JavaScript source extensions
+var tsExtensions = map[string]bool{
+	".ts": true, ".tsx": true, ".mts": true, ".cts": true,
+	".js": true, ".jsx": true, ".mjs": true, ".cjs": true,
+}
+
+// tsTestFile matches the *.test.ts and *.spec.ts conventions for every
+// extension
+var tsTestFile = regexp.MustCompile(`\.(test|spec)\.[cm]?[jt]sx?$`)
+
+// tsConfigFile matches tool configs such as jest.config.js and vite.config.ts
+var tsConfigFile = regexp.MustCompile(`\.config\.[cm]?[jt]s$`)
+
+// tsState is the npm package a directory belongs to
+type tsState struct {
+	// packageRoot is the slash-separated repo-relative directory of the
+	// enclosing package.json, or of a tsconfig.json outside any package
+	packageRoot string
+	packageName string
+	// inTests is set inside __tests__ directories
+	inTests bool
+}
+
+// Key identifies the state for cache fingerprints
+func (t tsState) Key() string {
+	key := t.packageRoot + ";" + t.packageName
+	if t.inTests {
+		key += ";tests"
+	}
+	return key
+}
+
+// Child returns the state for a subdirectory
+func (t tsState) Child(name string) DirState {
+	if name == "__tests__" {
+		t.inTests = true
+	}
+	return t
+}
+
+// packageJSON is the subset of package.json we use
+type packageJSON struct {
+	Name       string          `json:"name"`
+	Workspaces json.RawMessage `json:"workspaces"`
+}
+
+// enterDir applies a directory's package.json or tsconfig.json. A
+// package.json that only declares workspaces, or sits next to a
+// pnpm-workspace.yaml, is a workspace root rather than a package.
+func (t tsState) enterDir(dir *Dir) tsState {
+	relSlash := filepath.ToSlash(dir.RelPath)
+	var hasPackageJSON, hasTSConfig, isWorkspace bool
+	for _, entry := range dir.Entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch entry.Name() {
+		case "package.json":
+			hasPackageJSON = true
+		case "tsconfig.json":
+			hasTSConfig = true
+		case "pnpm-workspace.yaml":
+			isWorkspace = true
+		}
+	}
+
+	if hasPackageJSON {
+		var pkg packageJSON
+		if data, err := dir.ReadFile("package.json"); err == nil {
+			_ = json.Unmarshal(data, &pkg)
+		}
+		isWorkspace = isWorkspace || (len(pkg.Workspaces) > 0 && string(pkg.Workspaces) != "null")
+		if !isWorkspace {
+			t.packageRoot = relSlash
+			t.packageName = pkg.Name
+			t.inTests = false
+			return t
+		}
+	}
+	if hasTSConfig && !isWorkspace && t.packageRoot == "" {
+		t.packageRoot = relSlash
+	}
+	return t
+}
+
+// typescriptDetector groups TypeScript and JavaScript files by npm package
+type typescriptDetector struct{}
+
+func (typescriptDetector) Language() Language { return LangTypeScript }
+func (typescriptDetector) Name() string       { return "TypeScript/JavaScript" }
+
+func (typescriptDetector) RuleKinds() map[RuleRole][]string {
+	return map[RuleRole][]string{
+		RoleTest:    {"jest_test", "js_test", "vitest", "vitest_test"},
+		RoleLibrary: {"ts_project", "js_library"},
+		RoleBinary:  {"js_binary"},
+	}
+}
+
+func (typescriptDetector) Owns(filename string) bool {
+	return tsExtensions[filepath.Ext(filename)]
+}
+
+func (typescriptDetector) EnterDir(parent DirState, dir *Dir) DirState {
+	t, _ := parent.(tsState)
+	return t.enterDir(dir)
+}
+
+// ClassifyFile treats *.test.*, *.spec.* and files under __tests__ as tests,
+// and type declarations and tool configs as support files
+func (typescriptDetector) ClassifyFile(dir *Dir, filename string) (FileKind, ExclusionReason) {
+	switch {
+	case tsTestFile.MatchString(filename), dir.State.(tsState).inTests:
+		return FileTest, ""
+	case strings.HasSuffix(filename, ".d.ts"), strings.HasSuffix(filename, ".d.mts"),
+		strings.HasSuffix(filename, ".d.cts"), tsConfigFile.MatchString(filename):
+		return FileSupport, ""
+	}
+	return FileSource, ""
+}
+
+func (typescriptDetector) InitPackage(dir *Dir, pkg *Package) {}
+
+// Finish groups files under their package root. Directories outside any
+// package are packages on their own.
+func (typescriptDetector) Finish(dirs []*DirResult) ([]*Package, []*ExcludedPackage) {
+	pkgs := mergeUnderRoots(LangTypeScript, dirs, func(dr *DirResult) string {
+		return dr.State.(tsState).packageRoot
+	}, func(dr *DirResult, pkg *Package) {
+		pkg.NpmPackage = dr.State.(tsState).packageName
+	})
+
+	// Type declarations and configs alone do not make a package
+	kept := pkgs[:0]
+	for _, pkg := range pkgs {
+		if pkg.SourceFileCount > 0 || pkg.TestFileCount > 0 || pkg.GeneratedFileCount > 0 {
+			kept = append(kept, pkg)
+		}
+	}
+	return kept, nil
+}
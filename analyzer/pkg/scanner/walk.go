@@ -0,0 +1,244 @@
+package scanner
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dirJob is a directory waiting to be scanned, with the state it inherits
+// from its parent
+type dirJob struct {
+	path     string
+	relPath  string
+	excluded ExclusionReason
+	ignore   *gitignore     // .gitignore chain in effect for the parent directory
+	gazelle  *gazelleConfig // gazelle directives in effect for the parent directory
+	// workspace is the Bazel workspace of the parent directory
+	workspace *workspaceState
+	// states holds the per-language state passed down by the parent
+	states map[Language]DirState
+}
+
+// dirQueue is an unbounded work queue of directories. It tracks queued and
+// in-progress jobs so workers can tell when the walk is complete.
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    []dirJob
+	pending int
+	closed  bool
+}
+
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *dirQueue) push(job dirJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, job)
+	q.pending++
+	q.cond.Signal()
+}
+
+// pop blocks until a job is available. It returns false once every job is
+// done or the queue has been closed.
+func (q *dirQueue) pop() (dirJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.jobs) == 0 && q.pending > 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.jobs) == 0 || q.closed {
+		return dirJob{}, false
+	}
+	job := q.jobs[len(q.jobs)-1]
+	q.jobs = q.jobs[:len(q.jobs)-1]
+	return job, true
+}
+
+// done marks a popped job as finished
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// close wakes up all workers and makes pop return false
+func (q *dirQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// walkState is the per-scan state shared by all workers
+type walkState struct {
+	root  *workspaceState
+	cache *scanCache // nil when caching is disabled
+}
+
+// walk scans every directory under the repository on a pool of s.workers
+// goroutines and returns the per-directory results in no particular order
+func (s *Scanner) walk(ctx context.Context, state *walkState) ([]*dirPackages, error) {
+	// Ignore rules: .bazelignore entries per workspace, plus the .gitignore
+	// chain per directory
+	rootIgnore := &gitignore{}
+	if !s.scansTrackedFiles() {
+		rootIgnore = rootIgnore.child(loadIgnoreFile(s.fsys, ".git/info/exclude", ""))
+	}
+
+	queue := newDirQueue()
+	queue.push(dirJob{path: s.repoPath, relPath: ".", ignore: rootIgnore, gazelle: &gazelleConfig{}, workspace: state.root})
+
+	stop := context.AfterFunc(ctx, queue.close)
+	defer stop()
+
+	var (
+		mu   sync.Mutex
+		dirs []*dirPackages
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, ok := queue.pop()
+				if !ok {
+					return
+				}
+				dp, children := s.scanDir(job, state)
+				// Queue children before marking the job done so the
+				// pending count cannot drop to zero early
+				for _, child := range children {
+					queue.push(child)
+				}
+				mu.Lock()
+				dirs = append(dirs, dp)
+				mu.Unlock()
+				queue.done()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+// scanDir reads a single directory, classifying its files and returning
+// the subdirectories still to be scanned
+func (s *Scanner) scanDir(job dirJob, state *walkState) (*dirPackages, []dirJob) {
+	dp := &dirPackages{
+		path:      job.path,
+		relPath:   job.relPath,
+		excluded:  job.excluded,
+		workspace: job.workspace,
+	}
+
+	entries, err := fs.ReadDir(s.fsys, filepath.ToSlash(job.relPath))
+	if err != nil {
+		return dp, nil // Skip directories we can't read
+	}
+	dir := &Dir{Path: job.path, RelPath: job.relPath, Entries: entries, fsys: s.fsys}
+
+	// A nested workspace is scanned even if the enclosing workspace's
+	// .bazelignore lists it, and starts without the enclosing gazelle
+	// directives
+	gazelle := job.gazelle
+	if dp.excluded == "" || dp.excluded == ExcludedByBazelignore {
+		dp.workspace = job.workspace.child(dir)
+	}
+	if dp.workspace != job.workspace {
+		dp.excluded = ""
+		gazelle = &gazelleConfig{}
+	}
+
+	ignore := job.ignore
+	if dp.excluded == "" && !s.scansTrackedFiles() {
+		base := filepath.ToSlash(job.relPath)
+		if base == "." {
+			base = ""
+		}
+		ignore = ignore.child(loadIgnoreFile(s.fsys, dp.file(".gitignore"), base))
+	}
+	dp.gazelle = gazelle
+	if dp.excluded == "" {
+		dp.gazelle, dp.gazelleIgnore = gazelle.child(dir)
+	}
+	dp.states = make(map[Language]DirState, len(s.languages))
+	for _, d := range s.languages {
+		dp.states[d.Language()] = d.EnterDir(job.states[d.Language()], dir)
+	}
+
+	// Reuse the cached result if nothing this directory depends on changed
+	var fingerprint string
+	cached := false
+	if state.cache != nil {
+		fingerprint = s.dirFingerprint(dp, ignore, entries)
+		if cd := state.cache.lookup(dp.relPath, fingerprint); cd != nil {
+			dp.fromCache(cd)
+			state.cache.store(dp.relPath, cd)
+			cached = true
+		}
+	}
+
+	var children []dirJob
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if entry.IsDir() {
+			// Skip hidden and excluded directories
+			if strings.HasPrefix(name, ".") || s.skipDirs[name] || strings.HasPrefix(name, "bazel-") {
+				continue
+			}
+
+			child := dirJob{
+				path:      filepath.Join(job.path, name),
+				relPath:   filepath.Join(job.relPath, name),
+				excluded:  dp.excluded,
+				ignore:    ignore,
+				gazelle:   dp.gazelle,
+				workspace: dp.workspace,
+				states:    make(map[Language]DirState, len(dp.states)),
+			}
+			for lang, state := range dp.states {
+				child.states[lang] = state.Child(name)
+			}
+			relSlash := filepath.ToSlash(child.relPath)
+			if child.excluded == "" {
+				switch {
+				case bazelignored(dp.workspace.bazelIgnores, relSlash):
+					child.excluded = ExcludedByBazelignore
+				case ignore.ignored(relSlash, true):
+					child.excluded = ExcludedByGitignore
+				case dp.gazelle.excluded(relSlash):
+					child.excluded = ExcludedByGazelle
+				}
+			}
+			children = append(children, child)
+			continue
+		}
+
+		if !cached {
+			s.scanFile(dp, ignore, entries, name)
+		}
+	}
+
+	if state.cache != nil && !cached {
+		state.cache.store(dp.relPath, dp.toCache(fingerprint))
+	}
+
+	return dp, children
+}
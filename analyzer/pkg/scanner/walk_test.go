@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"bytes"
+	"io/fs"
+	"math/rand"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// jitterFS delays directory listings by a random amount, so concurrent
+// workers finish directories in a different order on every scan
+type jitterFS struct {
+	fstest.MapFS
+}
+
+func (f jitterFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+	return f.MapFS.ReadDir(name)
+}
+
+func TestScanDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"merged crate", rustModuleFS(120)},
+		{"mixed languages", mixedFS()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := marshal(t, scanFS(t, tt.fsys, WithWorkers(1)))
+			for run := 0; run < 5; run++ {
+				if got := marshal(t, scanFS(t, jitterFS{tt.fsys}, WithWorkers(8))); !bytes.Equal(got, want) {
+					t.Fatalf("run %d with 8 workers differs from the scan with 1 worker", run)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package scanner
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// workspaceFiles are the files that make a directory the root of a Bazel
+// workspace
+var workspaceFiles = map[string]bool{
+	"MODULE.bazel":    true,
+	"WORKSPACE.bazel": true,
+	"WORKSPACE":       true,
+}
+
+// Workspace is a Bazel workspace of the repository: the repository root, or
+// a directory below it with its own MODULE.bazel or WORKSPACE, like an
+// example project or the target of a local_repository. Bazel does not cross
+// into nested workspaces, so each one has its own package tree.
+type Workspace struct {
+	RelPath string `json:"relPath"`
+	// Name is the repository name the labels of the workspace's packages
+	// carry, e.g. "foo" in "@foo//pkg:target": the name an enclosing
+	// workspace declares it under with local_repository or
+	// local_path_override, else its module name, else its directory name.
+	// It is empty for the root workspace.
+	Name string `json:"name,omitempty"`
+	// Parent is the relPath of the enclosing workspace, empty for the root
+	Parent string `json:"parent,omitempty"`
+	// LocalRepository is set when an enclosing workspace declares the
+	// workspace as a local repository
+	LocalRepository bool `json:"localRepository,omitempty"`
+	// External holds the workspace's MODULE.bazel and WORKSPACE repositories
+	External *ExternalDeps `json:"external,omitempty"`
+}
+
+// workspaceState is the workspace a directory belongs to, passed down the
+// walk like the .gitignore chain
+type workspaceState struct {
+	ws *Workspace
+	// relSlash is the workspace's slash-separated repo-relative path
+	relSlash string
+	// bazelIgnores are the repo-relative directories listed in the
+	// workspace's .bazelignore
+	bazelIgnores []string
+	// localRepos maps the repo-relative directories of the local
+	// repositories declared by the workspace and those enclosing it to
+	// their names
+	localRepos map[string]string
+}
+
+// rootWorkspace returns the workspace at the root of the scanned file system
+func rootWorkspace(fsys fs.FS) *workspaceState {
+	return newWorkspaceState(fsys, &Workspace{RelPath: ".", External: loadExternalDeps(fsys, ".")}, nil)
+}
+
+func newWorkspaceState(fsys fs.FS, ws *Workspace, parent *workspaceState) *workspaceState {
+	relSlash := filepath.ToSlash(ws.RelPath)
+	w := &workspaceState{
+		ws:           ws,
+		relSlash:     relSlash,
+		bazelIgnores: loadBazelignore(fsys, relSlash),
+		localRepos:   make(map[string]string),
+	}
+	if parent != nil {
+		for dir, name := range parent.localRepos {
+			w.localRepos[dir] = name
+		}
+	}
+	if ws.External != nil {
+		for _, repo := range ws.External.Repos {
+			if repo.Path == "" || path.IsAbs(repo.Path) {
+				continue
+			}
+			// Paths outside the repository cannot be scanned
+			dir := path.Join(relSlash, filepath.ToSlash(repo.Path))
+			if dir == ".." || strings.HasPrefix(dir, "../") {
+				continue
+			}
+			w.localRepos[dir] = repo.Name
+		}
+	}
+	return w
+}
+
+// child returns the workspace of a directory: a new workspace nested in w if
+// the directory has a MODULE.bazel or WORKSPACE file, otherwise w
+func (w *workspaceState) child(dir *Dir) *workspaceState {
+	relSlash := filepath.ToSlash(dir.RelPath)
+	if relSlash == w.relSlash || !isWorkspaceRoot(dir.Entries) {
+		return w
+	}
+	ws := &Workspace{
+		RelPath:  dir.RelPath,
+		Parent:   w.ws.RelPath,
+		External: loadExternalDeps(dir.fsys, relSlash),
+	}
+	if name, ok := w.localRepos[relSlash]; ok {
+		ws.Name, ws.LocalRepository = name, true
+	} else if ws.External != nil && ws.External.ModuleName != "" {
+		ws.Name = ws.External.ModuleName
+	} else {
+		ws.Name = path.Base(relSlash)
+	}
+	return newWorkspaceState(dir.fsys, ws, w)
+}
+
+// isWorkspaceRoot reports whether a directory listing has a MODULE.bazel or
+// WORKSPACE file
+func isWorkspaceRoot(entries []fs.DirEntry) bool {
+	for _, entry := range entries {
+		if workspaceFiles[entry.Name()] && !entry.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies the workspace for scan cache fingerprints
+func (w *workspaceState) key() string {
+	return w.relSlash + "@" + w.ws.Name
+}
+
+// pkgPath returns a repo-relative directory's slash-separated path within
+// the workspace, "." for the workspace root
+func (w *workspaceState) pkgPath(relPath string) string {
+	rel := filepath.ToSlash(relPath)
+	switch {
+	case w.relSlash == ".":
+		return rel
+	case rel == w.relSlash:
+		return "."
+	}
+	return strings.TrimPrefix(rel, w.relSlash+"/")
+}
+
+// packageLabel returns the label of a Bazel package of the workspace, given
+// its repo-relative path, e.g. "//foo" in the root workspace or "@bar//foo"
+// in a nested one
+func (w *workspaceState) packageLabel(relPath string) string {
+	label := packageLabel(w.pkgPath(relPath))
+	if w.ws.Name != "" {
+		label = "@" + w.ws.Name + label
+	}
+	return label
+}
+
+// pkgPath returns the directory's slash-separated path within its
+// workspace, the package part of its labels
+func (dp *dirPackages) pkgPath() string {
+	return dp.workspace.pkgPath(dp.relPath)
+}
+
+// workspaceOf returns the workspace of a slash-separated repo-relative
+// path, found through its nearest scanned directory
+func workspaceOf(byRel map[string]*dirPackages, relSlash string) *workspaceState {
+	for p := relSlash; ; p = path.Dir(p) {
+		if dp := byRel[p]; dp != nil {
+			return dp.workspace
+		}
+		if p == "." {
+			return nil
+		}
+	}
+}
+
+// collectWorkspaces returns the root workspace and the nested workspaces
+// found by the walk, sorted by path
+func collectWorkspaces(root *workspaceState, dirs []*dirPackages) []*Workspace {
+	var nested []*Workspace
+	for _, dp := range dirs {
+		if w := dp.workspace; w != root && w.relSlash == filepath.ToSlash(dp.relPath) {
+			nested = append(nested, w.ws)
+		}
+	}
+	sort.Slice(nested, func(i, j int) bool {
+		return nested[i].RelPath < nested[j].RelPath
+	})
+	return append([]*Workspace{root.ws}, nested...)
+}
+
+// applyWorkspaces tags each language package with the workspace its
+// directory belongs to
+func applyWorkspaces(langs []*LanguageResult, byRel map[string]*dirPackages) {
+	for _, lr := range langs {
+		for _, pkg := range lr.Packages {
+			if w := workspaceOf(byRel, filepath.ToSlash(pkg.RelPath)); w != nil {
+				pkg.Workspace = w.ws.RelPath
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ REPO_BRANCH="${REPO_BRANCH:-main}"
 GCS_BUCKET="${GCS_BUCKET:-bazel-metrics-data}"
 RUN_BENCHMARKS="${RUN_BENCHMARKS:-false}"
 MAX_BENCHMARKS="${MAX_BENCHMARKS:-5}"
+ANALYZER_CONFIG="${ANALYZER_CONFIG:-}"
 
 echo "=== Bazel Metrics Analyzer Job ==="
 echo "Repo: $REPO_URL"
@@ -35,11 +36,16 @@ BENCHMARK_FLAG=""
 if [ "$RUN_BENCHMARKS" = "true" ]; then
     BENCHMARK_FLAG="--benchmark --max-benchmarks=$MAX_BENCHMARKS"
 fi
+CONFIG_FLAG=""
+if [ -n "$ANALYZER_CONFIG" ]; then
+    CONFIG_FLAG="--config=$ANALYZER_CONFIG"
+fi
 
 /usr/local/bin/analyzer \
     --repo="$WORK_DIR" \
     --output=/tmp/metrics.json \
-    $BENCHMARK_FLAG
+    $BENCHMARK_FLAG \
+    $CONFIG_FLAG
 
 echo ""
 echo "=== Uploading to GCS ==="
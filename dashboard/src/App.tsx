@@ -6,12 +6,33 @@ import { DirectoryBreakdown } from './components/DirectoryBreakdown';
 import { PackageExplorer } from './components/PackageExplorer';
 import { SpeedComparison } from './components/SpeedComparison';
 
-type Language = 'go' | 'python' | 'rust';
+type Language = 'go' | 'python' | 'rust' | 'java' | 'typescript' | 'cpp';
 
 const languageLabels: Record<Language, string> = {
   go: 'Go',
   python: 'Python',
   rust: 'Rust',
+  java: 'Java/Kotlin',
+  typescript: 'TypeScript/JavaScript',
+  cpp: 'C/C++',
+};
+
+const testTargetLabels: Record<Language, string> = {
+  go: 'go_test',
+  python: 'py_test',
+  rust: 'rust_test',
+  java: 'java_test',
+  typescript: 'jest_test',
+  cpp: 'cc_test',
+};
+
+const testFilePatterns: Record<Language, string> = {
+  go: '*_test.go',
+  python: '*_test.py',
+  rust: '*_test.rs',
+  java: '*Test.java',
+  typescript: '*.test.ts',
+  cpp: '*_test.cc',
 };
 
 
@@ -76,6 +97,9 @@ function App() {
 
   // Get packages for current language
   const getPackagesForLanguage = (lang: Language): PackageInfo[] => {
+    if (metrics.languagePackages) {
+      return metrics.languagePackages[lang] || [];
+    }
     switch (lang) {
       case 'go':
         return metrics.goPackages || metrics.packages;
@@ -105,10 +129,8 @@ function App() {
   };
 
   // Labels based on language
-  const testTargetLabel = activeLanguage === 'go' ? 'go_test' :
-                          activeLanguage === 'python' ? 'py_test' : 'rust_test';
-  const fileExtension = activeLanguage === 'go' ? '.go' :
-                        activeLanguage === 'python' ? '.py' : '.rs';
+  const testTargetLabel = testTargetLabels[activeLanguage];
+  const testFilePattern = testFilePatterns[activeLanguage];
 
   return (
     <div className="min-h-screen p-6">
@@ -192,7 +214,7 @@ function App() {
         <MetricCard
           title="Test Files"
           value={displaySummary.totalTestFiles.toLocaleString()}
-          subtitle={`*_test${fileExtension} files`}
+          subtitle={`${testFilePattern} files`}
           color="yellow"
         />
         <MetricCard
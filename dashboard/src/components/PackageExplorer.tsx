@@ -1,7 +1,7 @@
 import { useState, useMemo } from 'react';
 import type { PackageInfo, PackageBenchmark } from '../types/metrics';
 
-type Language = 'go' | 'python' | 'rust';
+type Language = 'go' | 'python' | 'rust' | 'java' | 'typescript' | 'cpp';
 
 interface PackageExplorerProps {
   packages: PackageInfo[];
@@ -15,6 +15,9 @@ const languageConfig: Record<Language, { testLabel: string; sourceLabel: string;
   go: { testLabel: 'Bazel Tests', sourceLabel: 'Source Go Files', fileExt: '_test.go' },
   python: { testLabel: 'py_test', sourceLabel: 'Source Py Files', fileExt: '_test.py' },
   rust: { testLabel: 'rust_test', sourceLabel: 'Rust Files', fileExt: '.rs' },
+  java: { testLabel: 'java_test', sourceLabel: 'Java/Kotlin Files', fileExt: 'Test.java' },
+  typescript: { testLabel: 'jest_test', sourceLabel: 'TS/JS Files', fileExt: '.test.ts' },
+  cpp: { testLabel: 'cc_test', sourceLabel: 'C/C++ Files', fileExt: '_test.cc' },
 };
 
 export function PackageExplorer({ packages, benchmarks = [], language = 'go' }: PackageExplorerProps) {
@@ -140,6 +143,7 @@ export function PackageExplorer({ packages, benchmarks = [], language = 'go' }:
               <th className="pb-2 pr-4 text-center" title={`Number of ${config.fileExt} files`}>Test Files</th>
               <th className="pb-2 pr-4 text-center" title={`Number of ${config.testLabel} targets in BUILD file`}>{config.testLabel}</th>
               <th className="pb-2 pr-4 text-center" title="Non-test source files">{config.sourceLabel}</th>
+              <th className="pb-2 pr-4 text-center" title="Targets of this language in BUILD files">Targets</th>
               {showBenchmarks && (
                 <>
                   <th className="pb-2 pr-4 text-center" title="go test execution time">Go Test Time</th>
@@ -156,13 +160,25 @@ export function PackageExplorer({ packages, benchmarks = [], language = 'go' }:
                 <td className="py-2 pr-4 text-center">
                   {pkg.hasBuildFile ? (
                     <span className="text-green-400">✓</span>
+                  ) : pkg.ownership === 'ancestor' ? (
+                    <span className="text-blue-400 cursor-help" title={`Built by ${pkg.bazelPackage}`}>↑</span>
                   ) : (
                     <span className="text-gray-600">-</span>
                   )}
                 </td>
                 <td className="py-2 pr-4 text-center">
                   {pkg.hasTestFiles ? (
-                    <span className="text-blue-400">{pkg.testFileCount}</span>
+                    <>
+                      <span className="text-blue-400">{pkg.testFileCount}</span>
+                      {pkg.uncoveredTestFiles && pkg.uncoveredTestFiles.length > 0 && (
+                        <span
+                          className="ml-1 text-yellow-400 cursor-help"
+                          title={`Not in any test target's srcs:\n${pkg.uncoveredTestFiles.join('\n')}`}
+                        >
+                          ({pkg.uncoveredTestFiles.length} not run)
+                        </span>
+                      )}
+                    </>
                   ) : (
                     <span className="text-gray-600">-</span>
                   )}
@@ -175,6 +191,18 @@ export function PackageExplorer({ packages, benchmarks = [], language = 'go' }:
                   )}
                 </td>
                 <td className="py-2 pr-4 text-center text-gray-400">{pkg.goFileCount}</td>
+                <td className="py-2 pr-4 text-center">
+                  {pkg.targets && pkg.targets.length > 0 ? (
+                    <span
+                      className="text-green-400 cursor-help"
+                      title={pkg.targets.map(t => `${t.kind} ${t.label}`).join('\n')}
+                    >
+                      {pkg.targets.length}
+                    </span>
+                  ) : (
+                    <span className="text-gray-600">-</span>
+                  )}
+                </td>
                 {showBenchmarks && (
                   <>
                     <td className="py-2 pr-4 text-center">
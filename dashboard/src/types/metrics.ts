@@ -1,4 +1,4 @@
-export type Language = 'go' | 'python' | 'rust';
+export type Language = 'go' | 'python' | 'rust' | 'java' | 'typescript' | 'cpp';
 
 export interface LanguageSummary {
   language: string;
@@ -11,6 +11,14 @@ export interface LanguageSummary {
   packagesWithBuild: number;
   packagesWithTests: number;
   totalTestTargets: number;
+  bazelizedTestFilesPct?: number;  // test files listed in a test target's srcs
+  uncoveredTestFiles?: number;
+  uncoveredSourceFiles?: number;
+  ownedPct?: number;                 // packages with a BUILD file or built by an ancestor package
+  packagesOwnedByAncestor?: number;
+  unownedPackages?: number;
+  totalGeneratedFiles?: number;      // generated source files, not in totalSourceFiles
+  generatedOnlyPackages?: number;    // packages with nothing but generated files
 }
 
 // Kept for backwards compatibility
@@ -36,6 +44,34 @@ export interface DirectoryMetrics {
   testCoveragePct: number;
 }
 
+export interface Glob {
+  include: string[];
+  exclude?: string[];
+}
+
+export interface Target {
+  name: string;
+  kind: string;
+  label: string;          // e.g. //foo/bar:baz
+  language?: string;      // set for known or configured rule kinds
+  role?: 'test' | 'library' | 'binary';
+  srcs: string[];
+  srcGlobs?: Glob[];
+  hdrs?: string[];
+  hdrGlobs?: Glob[];
+  srcsDynamic?: boolean;  // srcs or hdrs could not be evaluated statically
+  crate?: string;         // the crate a rust_test tests
+  data?: string[];
+  dataGlobs?: Glob[];
+  dataDynamic?: boolean;
+  outs?: string[];        // declared output files, e.g. a genrule's outs
+  keep?: boolean;         // has a gazelle # keep comment
+  depsCount: number;
+  tags?: string[];
+  size?: string;
+  visibility?: string[];
+}
+
 export interface PackageInfo {
   path: string;
   language?: string;
@@ -44,6 +80,24 @@ export interface PackageInfo {
   testFileCount: number;
   goTestTargetCount: number;  // kept for backwards compat, represents testTargetCount
   goFileCount: number;        // kept for backwards compat, represents sourceFileCount
+  libraryTargetCount?: number;
+  binaryTargetCount?: number;
+  targets?: Target[];         // the rules of the package's BUILD files, of any language
+  modulePath?: string;        // Go only
+  importPath?: string;        // Go import path, or Python dotted package path
+  gazelleImportPath?: string; // Go only: import path implied by # gazelle:prefix
+  goNamingConvention?: string; // Go only: from # gazelle:go_naming_convention
+  supportFileCount?: number;  // Python only: __init__.py, conftest.py, setup.py, test helpers
+  generatedFileCount?: number; // generated source files, not in goFileCount
+  crateName?: string;         // Rust only: package name from Cargo.toml
+  cargoWorkspace?: string;    // Rust only: root of the Cargo workspace the crate belongs to
+  buildTool?: string;         // Java only: "maven" or "gradle" for a module package
+  npmPackage?: string;        // TypeScript only: name from package.json
+  bazelPackage?: string;            // label of the nearest Bazel package, e.g. //foo
+  ownership?: 'build' | 'ancestor' | 'unowned';
+  workspace?: string;               // relPath of the package's Bazel workspace, "." for the root
+  uncoveredTestFiles?: string[];    // test files in no test target's srcs
+  uncoveredSourceFiles?: string[];  // source files in no target's srcs
 }
 
 export interface PackageBenchmark {
@@ -51,15 +105,126 @@ export interface PackageBenchmark {
   goTestMs: number;
   bazelTestColdMs: number;
   bazelTestWarmMs: number;
+  workspace?: string;  // Bazel workspace bazel test ran in, when not the root
 }
 
 export interface SpeedReport {
   packages: PackageBenchmark[];
 }
 
+export interface ExcludedPackage {
+  relPath: string;
+  language: string;
+  reason: string;  // e.g. "bazelignore", "gitignore", "gazelle-exclude", "go-testdata", "go-no-module"
+}
+
+export interface ExclusionSummary {
+  totalPackages: number;
+  byReason: Record<string, number>;
+  packages: ExcludedPackage[];
+  byDirective?: ExcludedPackage[];  // excluded by # gazelle:exclude or # gazelle:ignore
+}
+
+export interface CacheStats {
+  enabled: boolean;
+  hits: number;
+  misses: number;
+}
+
+export interface ProtoPackage {
+  path: string;
+  relPath: string;
+  protoPackage?: string;
+  protoFileCount: number;
+  hasProtoLibrary: boolean;
+  bindings: string[];                        // languages with a *_proto_library rule
+  generatedFiles?: Record<string, number>;   // checked-in protoc output per language
+  generatedOutsideBazel: string[];           // languages generated without a Bazel binding
+}
+
+export interface BazelPackage {
+  label: string;
+  relPath: string;
+  parent?: string;       // label of the enclosing Bazel package
+  workspace?: string;    // relPath of the package's Bazel workspace
+  sourceDirs: string[];  // directories without a BUILD file that the package owns
+}
+
+export interface ExternalRepo {
+  name: string;
+  kind: string;        // bazel_dep, use_repo, or a repository rule such as http_archive
+  bzlmod: boolean;     // defined in MODULE.bazel
+  module?: string;     // bazel_dep module name
+  extension?: string;  // e.g. @gazelle//:extensions.bzl%go_deps
+  version?: string;
+  path?: string;       // directory of a local_repository or local_path_override
+}
+
+export interface BzlmodSummary {
+  bazelVersion?: string;
+  hasModule: boolean;
+  hasWorkspace: boolean;
+  moduleName?: string;
+  moduleVersion?: string;
+  totalRepos: number;
+  bzlmodRepos: number;
+  workspaceOnlyRepos: number;
+  migrationPct: number;
+  byKind: Record<string, number>;
+  rulesVersions: Record<string, string>;  // rules_go, rules_python, rules_rust
+  legacyRepos: ExternalRepo[];
+  repos: ExternalRepo[];
+}
+
+export interface WorkspaceSummary {
+  relPath: string;
+  name?: string;              // repository name in the workspace's labels, e.g. foo in @foo//pkg; unset for the root
+  parent?: string;            // relPath of the enclosing workspace
+  localRepository?: boolean;  // declared by local_repository or local_path_override
+  buildPackages: number;
+  totalPackages: number;
+  packagesWithBuild: number;
+  bazelizationPct: number;
+  languages: string[];
+  languageSummaries: Record<string, LanguageSummary>;
+  bzlmod?: BzlmodSummary;
+}
+
+export interface MissingFile {
+  target: string;  // label of the target listing the file
+  attr: 'srcs' | 'hdrs' | 'data';
+  file: string;
+}
+
+export interface StaleBuild {
+  relPath: string;
+  missingFiles: MissingFile[];  // listed files that do not exist
+  unlistedFiles: string[];      // files no rule of the package lists
+}
+
+export interface BuildHygieneSummary {
+  totalPackages: number;  // directories with a BUILD file
+  stalePackages: number;
+  missingFiles: number;
+  unlistedFiles: number;
+  packages: StaleBuild[];
+}
+
+export interface ProtoSummary {
+  totalPackages: number;
+  totalProtoFiles: number;
+  packagesWithLibrary: number;
+  protoLibraryPct: number;
+  bindings: Record<string, number>;
+  generatedOutsideBazel: ProtoPackage[];
+  packages: ProtoPackage[];
+}
+
 export interface MetricsReport {
   timestamp: string;
   repoPath: string;
+  commit?: string;            // set by backfill; timestamp is then the commit time
+  excludeGenerated?: boolean; // generated-only packages are left out of totals and percentages
   summary: Summary;
   directoryBreakdown: DirectoryMetrics[];
   packages: PackageInfo[];
@@ -68,7 +233,37 @@ export interface MetricsReport {
   // Multi-language support
   languages?: string[];
   languageSummaries?: Record<string, LanguageSummary>;
+  languagePackages?: Record<string, PackageInfo[]>;
+  // Per-language package lists written by older analyzer versions
   goPackages?: PackageInfo[];
   pythonPackages?: PackageInfo[];
   rustPackages?: PackageInfo[];
+
+  exclusions?: ExclusionSummary;
+  proto?: ProtoSummary;
+  buildHygiene?: BuildHygieneSummary;
+  bzlmod?: BzlmodSummary;
+  bazelPackages?: BazelPackage[];
+  workspaces?: WorkspaceSummary[];  // the root workspace, then nested ones
+  targets?: Target[];         // every target in the repo, sorted by label
+  scanCache?: CacheStats;
+}
+
+// history.json, written by `bazel-metrics backfill`
+export interface HistoryPoint {
+  timestamp: string;          // commit time
+  commit: string;
+  summary: Summary;
+  languages: string[];
+  languageSummaries: Record<string, LanguageSummary>;
+  buildPackages: number;
+  stalePackages: number;
+  bzlmodMigrationPct?: number; // unset while the repo had neither MODULE.bazel nor WORKSPACE
+}
+
+export interface History {
+  repoPath: string;
+  ref: string;
+  interval: string;
+  points: HistoryPoint[];     // oldest first
 }